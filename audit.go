@@ -0,0 +1,208 @@
+package elasticsearch
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// The audit fields are marked by the flags createdAt, updatedAt, createdBy and updatedBy of the es tag, such as es:"date,createdAt" or es:"keyword,updatedBy".
+// A time field is a time.Time, a *time.Time, an integer of milliseconds or a RFC 3339 string; a user field is a string or a *string.
+const (
+	CreatedAt = "createdAt"
+	UpdatedAt = "updatedAt"
+	CreatedBy = "createdBy"
+	UpdatedBy = "updatedBy"
+)
+
+type auditKey struct{}
+
+// Audit gives the acting user and the time of the audit fields, of the soft deletes and of the history.
+// The user is the string of the context by UserKey, or by "userId" if UserKey is nil, unless GetUser is set; the time is time.Now unless Now is set.
+type Audit struct {
+	UserKey interface{}
+	GetUser func(ctx context.Context) string
+	Now     func() time.Time
+}
+
+// WithAudit returns a context which makes the functions of this package use the audit for this call.
+func WithAudit(ctx context.Context, audit *Audit) context.Context {
+	return context.WithValue(ctx, auditKey{}, audit)
+}
+
+// AuditFromContext returns the audit of the context, or nil if the context has no audit.
+func AuditFromContext(ctx context.Context) *Audit {
+	if ctx == nil {
+		return nil
+	}
+	audit, _ := ctx.Value(auditKey{}).(*Audit)
+	return audit
+}
+
+// withDefaultAudit sets the audit of a wrapper in the context, unless the context already has an audit.
+func withDefaultAudit(ctx context.Context, audit *Audit) context.Context {
+	if audit == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(auditKey{}).(*Audit); ok {
+		return ctx
+	}
+	return WithAudit(ctx, audit)
+}
+
+type auditField struct {
+	flag  string
+	field StructField
+}
+
+// auditUser returns the acting user of the context.
+func auditUser(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	var key interface{} = "userId"
+	if audit := AuditFromContext(ctx); audit != nil {
+		if audit.GetUser != nil {
+			return audit.GetUser(ctx)
+		}
+		if audit.UserKey != nil {
+			key = audit.UserKey
+		}
+	}
+	user, _ := ctx.Value(key).(string)
+	return user
+}
+
+// auditNow returns the time of the audit of the context.
+func auditNow(ctx context.Context) time.Time {
+	if audit := AuditFromContext(ctx); audit != nil && audit.Now != nil {
+		return audit.Now()
+	}
+	return time.Now()
+}
+
+// SetAuditFields sets the audit fields of the model: createdAt and createdBy for an insert, if they are empty, and updatedAt and updatedBy for an insert or an update.
+// The model is returned; if it is not a pointer, a copy is set and returned.
+func SetAuditFields(ctx context.Context, model interface{}, insert bool) interface{} {
+	if model == nil {
+		return model
+	}
+	meta := GetMetadata(reflect.TypeOf(model))
+	if len(meta.audit) == 0 {
+		return model
+	}
+	value := reflect.ValueOf(model)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return model
+		}
+		meta.setAudit(ctx, value.Elem(), insert)
+		return model
+	}
+	copied := reflect.New(value.Type())
+	copied.Elem().Set(value)
+	meta.setAudit(ctx, copied.Elem(), insert)
+	return copied.Elem().Interface()
+}
+
+// SetAuditMap sets updatedAt and updatedBy in the document of a patch, by their json names.
+func SetAuditMap(ctx context.Context, modelType reflect.Type, model map[string]interface{}) {
+	meta := GetMetadata(modelType)
+	if len(meta.audit) == 0 || model == nil {
+		return
+	}
+	now := auditNow(ctx)
+	user := auditUser(ctx)
+	for _, a := range meta.audit {
+		switch a.flag {
+		case UpdatedAt:
+			if v, ok := auditTimeValue(a.field.Type, now); ok {
+				model[a.field.JsonName] = v.Interface()
+			}
+		case UpdatedBy:
+			if len(user) > 0 {
+				model[a.field.JsonName] = user
+			}
+		}
+	}
+}
+
+// setAuditValue sets the audit fields of an item of a slice, a pointer or an addressable struct.
+func setAuditValue(ctx context.Context, v reflect.Value, insert bool) {
+	if v.Kind() == reflect.Ptr {
+		SetAuditFields(ctx, v.Interface(), insert)
+	} else if v.CanAddr() {
+		SetAuditFields(ctx, v.Addr().Interface(), insert)
+	}
+}
+
+// setAudit sets the audit fields of v, an addressable struct.
+func (m *Metadata) setAudit(ctx context.Context, v reflect.Value, insert bool) {
+	now := auditNow(ctx)
+	user := auditUser(ctx)
+	for _, a := range m.audit {
+		if !insert && (a.flag == CreatedAt || a.flag == CreatedBy) {
+			continue
+		}
+		f := fieldByIndexAlloc(v, a.field.Index)
+		if !f.IsValid() || !f.CanSet() {
+			continue
+		}
+		if (a.flag == CreatedAt || a.flag == CreatedBy) && !f.IsZero() {
+			continue
+		}
+		switch a.flag {
+		case CreatedAt, UpdatedAt:
+			if t, ok := auditTimeValue(f.Type(), now); ok {
+				f.Set(t)
+			}
+		case CreatedBy, UpdatedBy:
+			if len(user) > 0 {
+				setAuditUser(f, user)
+			}
+		}
+	}
+}
+
+// emptyCreated returns the json names of createdAt and createdBy if they are empty in the model.
+func (m *Metadata) emptyCreated(model interface{}) []string {
+	var names []string
+	value := reflect.ValueOf(model)
+	for _, a := range m.audit {
+		if a.flag != CreatedAt && a.flag != CreatedBy {
+			continue
+		}
+		if f, ok := FieldByIndex(value, a.field.Index); !ok || f.IsZero() {
+			names = append(names, a.field.JsonName)
+		}
+	}
+	return names
+}
+
+func auditTimeValue(t reflect.Type, now time.Time) (reflect.Value, bool) {
+	timeType := reflect.TypeOf(now)
+	switch {
+	case t == timeType:
+		return reflect.ValueOf(now), true
+	case t.Kind() == reflect.Ptr && t.Elem() == timeType:
+		return reflect.ValueOf(&now), true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return reflect.ValueOf(now.UnixNano() / int64(time.Millisecond)).Convert(t), true
+	case reflect.String:
+		return reflect.ValueOf(now.Format(time.RFC3339)).Convert(t), true
+	}
+	return reflect.Value{}, false
+}
+
+func setAuditUser(f reflect.Value, user string) {
+	switch {
+	case f.Kind() == reflect.String:
+		f.SetString(user)
+	case f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.String:
+		p := reflect.New(f.Type().Elem())
+		p.Elem().SetString(user)
+		f.Set(p)
+	}
+}
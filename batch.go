@@ -6,167 +6,163 @@ import (
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"reflect"
+	"sync"
 )
 
 type BatchInserter struct {
 	Es        *elasticsearch.Client
 	IndexName string
 	ModelType reflect.Type
+	GetIndex  func(model interface{}) string
+	Pipeline  string
+	Map       func(ctx context.Context, model interface{}) (interface{}, error)
+	Audit     *Audit
 }
 
 func NewBatchInserter(es *elasticsearch.Client, indexName string, modelType reflect.Type, options ...func(context.Context, interface{}) (interface{}, error)) *BatchInserter {
-	return &BatchInserter{Es: es, IndexName: indexName, ModelType: modelType}
+	var mp func(context.Context, interface{}) (interface{}, error)
+	if len(options) > 0 {
+		mp = options[0]
+	}
+	return &BatchInserter{Es: es, IndexName: indexName, ModelType: modelType, Map: mp}
+}
+
+func NewBatchInserterWithIndex(es *elasticsearch.Client, getIndex func(model interface{}) string, modelType reflect.Type, options ...func(context.Context, interface{}) (interface{}, error)) *BatchInserter {
+	inserter := NewBatchInserter(es, "", modelType, options...)
+	inserter.GetIndex = getIndex
+	return inserter
 }
 
+// Write inserts the models, a slice, with the bulk API, and returns the indices of the models which are inserted and of the models which fail. The models are mapped by Map, if it is set, after their audit fields are set; a mapping or an encryption error stops the write before any model is sent.
 func (w *BatchInserter) Write(ctx context.Context, model interface{}) ([]int, []int, error) {
+	ctx = withDefaultAudit(ctx, w.Audit)
 	value := reflect.Indirect(reflect.ValueOf(model))
-	var failureIndex, successIndices, failureIndices []int
-	if value.Kind() == reflect.Slice && value.Len() > 0 {
-		bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-			Index:  w.IndexName,
-			Client: w.Es,
-		})
+	if value.Kind() != reflect.Slice || value.Len() == 0 {
+		return nil, nil, errors.New("invalid input")
+	}
+	for i := 0; i < value.Len(); i++ {
+		setAuditValue(ctx, value.Index(i), true)
+	}
+	var docs interface{} = model
+	if w.Map != nil {
+		models, err := MapToDbModels(ctx, model, w.Map)
 		if err != nil {
-			return successIndices, failureIndices, err
-		}
-		listIds := FindListIdField(w.ModelType, model)
-		var successIds, failIds []interface{}
-		for i := 0; i < value.Len(); i++ {
-			sliceValue := value.Index(i).Interface()
-			if idIndex, _, _ := FindIdField(w.ModelType); idIndex >= 0 {
-				modelValue := reflect.Indirect(reflect.ValueOf(sliceValue))
-				idValue := modelValue.Field(idIndex).String()
-				if idValue != "" {
-					body := BuildQueryWithoutIdFromObject(sliceValue)
-					er1 := bi.Add(context.Background(), esutil.BulkIndexerItem{
-						Action:     "create",
-						DocumentID: idValue,
-						Body:       esutil.NewJSONReader(body),
-						OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-							successIds = append(successIds, res.DocumentID)
-						},
-						OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-							failIds = append(failIds, res.DocumentID)
-						},
-					})
-					if er1 != nil {
-						failureIndex = append(failureIndex, int(i))
-					}
-				} else {
-					failureIndex = append(failureIndex, int(i))
-				}
-			} else {
-				failureIndex = append(failureIndex, int(i))
-			}
+			return nil, nil, err
 		}
-		if er2 := bi.Close(context.Background()); er2 != nil {
-			return successIndices, failureIndices, er2
-		}
-		successIndices, failureIndices = BuildIndicesResult(listIds, successIds, failIds)
-		failureIndices = append(failureIndices, failureIndex...)
-		return successIndices, failureIndices, nil
+		docs = models
 	}
-	return successIndices, failureIndices, errors.New("invalid input")
+	return writeMany(ctx, w.Es, w.IndexName, w.ModelType, "create", model, docs, getPipeline(ctx, w.Pipeline), w.GetIndex)
 }
 
-func InsertMany(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}) ([]int, []int, error) {
-	value := reflect.Indirect(reflect.ValueOf(model))
+// InsertMany writes the documents with the bulk API. The optional parameter is the ingest pipeline to preprocess the documents.
+// It returns the indices in model of the documents which are written and of the documents which fail. An encryption error stops the write before any document is sent.
+func InsertMany(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}, options ...string) ([]int, []int, error) {
+	var pipeline string
+	if len(options) > 0 {
+		pipeline = options[0]
+	}
+	return writeMany(ctx, es, indexName, modelType, "create", model, model, pipeline, nil)
+}
+
+// UpsertMany writes the documents with the bulk API. The optional parameter is the ingest pipeline to preprocess the documents.
+// It returns the indices in model of the documents which are written and of the documents which fail. An encryption error stops the write before any document is sent.
+func UpsertMany(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}, options ...string) ([]int, []int, error) {
+	var pipeline string
+	if len(options) > 0 {
+		pipeline = options[0]
+	}
+	return writeMany(ctx, es, indexName, modelType, "index", model, model, pipeline, nil)
+}
+
+// writeMany writes the documents with the bulk API by the action, create or index. The ids are taken from models, a slice, and the documents from docs, a slice of the models or of the results of their mapping in the same order.
+// It returns the indices of the models which are written and of the models which fail; an encryption error stops the write before any document is sent.
+func writeMany(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, action string, models interface{}, docs interface{}, pipeline string, getIndex func(model interface{}) string) ([]int, []int, error) {
+	value := reflect.Indirect(reflect.ValueOf(models))
+	docValue := reflect.Indirect(reflect.ValueOf(docs))
 	var failureIndex, successIndices, failureIndices []int
-	if value.Kind() == reflect.Slice && value.Len() > 0 {
-		bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-			Index:  indexName,
-			Client: es,
-		})
-		if err != nil {
-			return successIndices, failureIndices, err
+	if value.Kind() != reflect.Slice || value.Len() == 0 || docValue.Kind() != reflect.Slice || docValue.Len() != value.Len() {
+		return successIndices, failureIndices, errors.New("invalid input")
+	}
+	listIds := FindListIdField(modelType, models)
+	bodies, err := encryptBodies(ctx, modelType, listIds, docValue)
+	if err != nil {
+		return successIndices, failureIndices, err
+	}
+	bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:    indexName,
+		Client:   es,
+		Pipeline: pipeline,
+	})
+	if err != nil {
+		return successIndices, failureIndices, err
+	}
+	var mu sync.Mutex
+	var successIds, failIds []interface{}
+	for i := 0; i < value.Len(); i++ {
+		if bodies[i] == nil {
+			failureIndex = append(failureIndex, i)
+			continue
 		}
-		listIds := FindListIdField(modelType, model)
-		var successIds, failIds []interface{}
-		for i := 0; i < value.Len(); i++ {
-			sliceValue := value.Index(i).Interface()
-			if idIndex, _, _ := FindIdField(modelType); idIndex >= 0 {
-				modelValue := reflect.Indirect(reflect.ValueOf(sliceValue))
-				idValue := modelValue.Field(idIndex).String()
-				if idValue != "" {
-					body := BuildQueryWithoutIdFromObject(sliceValue)
-					er1 := bi.Add(context.Background(), esutil.BulkIndexerItem{
-						Action:     "create",
-						DocumentID: idValue,
-						Body:       esutil.NewJSONReader(body),
-						OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-							successIds = append(successIds, res.DocumentID)
-						},
-						OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-							failIds = append(failIds, res.DocumentID)
-						},
-					})
-					if er1 != nil {
-						failureIndex = append(failureIndex, i)
-					}
-				} else {
-					failureIndex = append(failureIndex, i)
-				}
-			} else {
-				failureIndex = append(failureIndex, i)
-			}
+		var index string
+		if getIndex != nil {
+			index = getIndex(docValue.Index(i).Interface())
 		}
-		if er2 := bi.Close(context.Background()); er2 != nil {
-			return successIndices, failureIndices, er2
+		er1 := bi.Add(context.Background(), esutil.BulkIndexerItem{
+			Index:      index,
+			Action:     action,
+			DocumentID: listIds[i].(string),
+			Body:       NewReader(ctx, bodies[i]),
+			OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+				mu.Lock()
+				successIds = append(successIds, res.DocumentID)
+				mu.Unlock()
+			},
+			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				mu.Lock()
+				failIds = append(failIds, res.DocumentID)
+				mu.Unlock()
+			},
+		})
+		if er1 != nil {
+			failureIndex = append(failureIndex, i)
 		}
-		successIndices, failureIndices = BuildIndicesResult(listIds, successIds, failIds)
-		failureIndices = append(failureIndices, failureIndex...)
-		return successIndices, failureIndices, nil
 	}
-	return successIndices, failureIndices, errors.New("invalid input")
+	if er2 := bi.Close(context.Background()); er2 != nil {
+		return successIndices, failureIndices, er2
+	}
+	successIndices, failureIndices = modelIndices(listIds, successIds, failIds)
+	failureIndices = append(failureIndices, failureIndex...)
+	return successIndices, failureIndices, nil
 }
 
-func UpsertMany(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}) ([]int, []int, error) {
-	value := reflect.Indirect(reflect.ValueOf(model))
-	var failureIndex, successIndices, failureIndices []int
-	if value.Kind() == reflect.Slice && value.Len() > 0 {
-		bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-			Index:  indexName,
-			Client: es,
-		})
-		if err != nil {
-			return successIndices, failureIndices, err
+// encryptBodies returns the encrypted documents of docs, without their ids, in the order of the slice; the document of a model without id is nil. It returns the first encryption error.
+func encryptBodies(ctx context.Context, modelType reflect.Type, ids []interface{}, docs reflect.Value) ([]map[string]interface{}, error) {
+	bodies := make([]map[string]interface{}, docs.Len())
+	for i := 0; i < docs.Len() && i < len(ids); i++ {
+		if ids[i] == "" {
+			continue
+		}
+		body := documentBody(modelType, docs.Index(i).Interface())
+		if err := EncryptBody(ctx, modelType, body); err != nil {
+			return nil, err
 		}
-		listIds := FindListIdField(modelType, model)
-		var successIds, failIds []interface{}
-		for i := 0; i < value.Len(); i++ {
-			sliceValue := value.Index(i).Interface()
-			if idIndex, _, _ := FindIdField(modelType); idIndex >= 0 {
-				modelValue := reflect.Indirect(reflect.ValueOf(sliceValue))
-				idValue := modelValue.Field(idIndex).String()
-				if idValue != "" {
-					body := BuildQueryWithoutIdFromObject(sliceValue)
-					er1 := bi.Add(context.Background(), esutil.BulkIndexerItem{
-						Action:     "index",
-						DocumentID: idValue,
-						Body:       esutil.NewJSONReader(body),
-						OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-							successIds = append(successIds, res.DocumentID)
-						},
-						OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-							failIds = append(failIds, res.DocumentID)
-						},
-					})
-					if er1 != nil {
-						failureIndex = append(failureIndex, i)
-					}
-				} else {
-					failureIndex = append(failureIndex, i)
-				}
-			} else {
-				failureIndex = append(failureIndex, i)
-			}
+		bodies[i] = body
+	}
+	return bodies, nil
+}
+
+// modelIndices returns the indices in listIds of the ids which succeed and of the ids which fail, unlike BuildIndicesResult which returns their positions in successIds and failIds,
+// since the bulk indexer reports the items in the order of its responses, not in the order of the models.
+func modelIndices(listIds, successIds, failIds []interface{}) (successIndices, failureIndices []int) {
+	success := positions(successIds)
+	failure := positions(failIds)
+	for index, idValue := range listIds {
+		if len(success[idValue]) > 0 {
+			successIndices = append(successIndices, index)
 		}
-		if er2 := bi.Close(context.Background()); er2 != nil {
-			return successIndices, failureIndices, er2
+		if len(failure[idValue]) > 0 {
+			failureIndices = append(failureIndices, index)
 		}
-		successIndices, failureIndices = BuildIndicesResult(listIds, successIds, failIds)
-		failureIndices = append(failureIndices, failureIndex...)
-		return successIndices, failureIndices, nil
 	}
-	return successIndices, failureIndices, errors.New("invalid input")
+	return
 }
@@ -0,0 +1,322 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+	"time"
+)
+
+type Script struct {
+	Source string                 `json:"source,omitempty"`
+	Id     string                 `json:"id,omitempty"`
+	Lang   string                 `json:"lang,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+func NewScript(source string, params map[string]interface{}) Script {
+	return Script{Source: source, Lang: "painless", Params: params}
+}
+
+// ByQueryOptions are the options of UpdateByQuery and DeleteByQuery.
+// Conflicts is "abort" (default) or "proceed"; Slices is a number of slices or "auto"; RequestsPerSecond throttles the operation, -1 means no throttling.
+type ByQueryOptions struct {
+	Conflicts         string
+	RequestsPerSecond *int
+	Slices            interface{}
+	MaxDocs           *int
+	Refresh           bool
+	Pipeline          string
+}
+
+type ByQueryResult struct {
+	Took              int64                    `json:"took,omitempty"`
+	TimedOut          bool                     `json:"timed_out,omitempty"`
+	Total             int64                    `json:"total"`
+	Created           int64                    `json:"created,omitempty"`
+	Updated           int64                    `json:"updated"`
+	Deleted           int64                    `json:"deleted"`
+	Batches           int64                    `json:"batches"`
+	VersionConflicts  int64                    `json:"version_conflicts"`
+	Noops             int64                    `json:"noops"`
+	RequestsPerSecond float64                  `json:"requests_per_second"`
+	Failures          []map[string]interface{} `json:"failures,omitempty"`
+}
+
+// ByQueryError is the error of an update by query, a delete by query or a reindex whose response has failures, such as version conflicts or bulk rejections.
+// The documents before the failures are already processed, and Result has their counts.
+type ByQueryError struct {
+	Result *ByQueryResult
+}
+
+func (e *ByQueryError) Error() string {
+	return fmt.Sprintf("%d failures, the first of them: %v", len(e.Result.Failures), e.Result.Failures[0])
+}
+
+type TaskStatus struct {
+	Completed   bool                   `json:"completed"`
+	Status      *ByQueryResult         `json:"status,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Cancelled   bool                   `json:"cancelled,omitempty"`
+	Response    *ByQueryResult         `json:"response,omitempty"`
+	Error       map[string]interface{} `json:"error,omitempty"`
+}
+
+// UpdateByQuery runs the script on every document matching the query and waits for completion. If the response has failures, the result is returned with a *ByQueryError.
+// The query is the body of a search request, as built by SearchBuilder.BuildQuery, or only its "query" clause.
+func UpdateByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, script Script, options ...ByQueryOptions) (*ByQueryResult, error) {
+	res, err := doUpdateByQuery(ctx, es, indexName, query, script, true, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return decodeByQueryResult(ctx, res)
+}
+
+// StartUpdateByQuery runs UpdateByQuery as a task and returns the task id, to poll with GetTask or to cancel with CancelTask.
+func StartUpdateByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, script Script, options ...ByQueryOptions) (string, error) {
+	res, err := doUpdateByQuery(ctx, es, indexName, query, script, false, options...)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	return decodeTaskId(ctx, res)
+}
+
+// DeleteByQuery deletes every document matching the query and waits for completion. If the response has failures, the result is returned with a *ByQueryError.
+func DeleteByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, options ...ByQueryOptions) (*ByQueryResult, error) {
+	res, err := doDeleteByQuery(ctx, es, indexName, query, true, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return decodeByQueryResult(ctx, res)
+}
+
+// StartDeleteByQuery runs DeleteByQuery as a task and returns the task id.
+func StartDeleteByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, options ...ByQueryOptions) (string, error) {
+	res, err := doDeleteByQuery(ctx, es, indexName, query, false, options...)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	return decodeTaskId(ctx, res)
+}
+
+func doUpdateByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, script Script, wait bool, options ...ByQueryOptions) (*esapi.Response, error) {
+	body := map[string]interface{}{"query": BuildQueryBody(query)["query"], "script": script}
+	req := esapi.UpdateByQueryRequest{
+		Index:             pathIndices(indexName),
+		Body:              NewReader(ctx, body),
+		WaitForCompletion: &wait,
+	}
+	if len(options) > 0 {
+		o := options[0]
+		req.Conflicts = o.Conflicts
+		req.RequestsPerSecond = o.RequestsPerSecond
+		req.Slices = o.Slices
+		req.MaxDocs = o.MaxDocs
+		req.Pipeline = o.Pipeline
+		if o.Refresh {
+			req.Refresh = &o.Refresh
+		}
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		res.Body.Close()
+		return nil, errors.New("response error")
+	}
+	return res, nil
+}
+
+func doDeleteByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, wait bool, options ...ByQueryOptions) (*esapi.Response, error) {
+	req := esapi.DeleteByQueryRequest{
+		Index:             pathIndices(indexName),
+		Body:              NewReader(ctx, map[string]interface{}{"query": BuildQueryBody(query)["query"]}),
+		WaitForCompletion: &wait,
+	}
+	if len(options) > 0 {
+		o := options[0]
+		req.Conflicts = o.Conflicts
+		req.RequestsPerSecond = o.RequestsPerSecond
+		req.Slices = o.Slices
+		req.MaxDocs = o.MaxDocs
+		if o.Refresh {
+			req.Refresh = &o.Refresh
+		}
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		res.Body.Close()
+		return nil, errors.New("response error")
+	}
+	return res, nil
+}
+
+// BuildQueryBody returns a request body from a search body or from a query clause. An empty query matches all documents.
+func BuildQueryBody(query map[string]interface{}) map[string]interface{} {
+	body := make(map[string]interface{})
+	if len(query) == 0 {
+		body["query"] = map[string]interface{}{"match_all": map[string]interface{}{}}
+		return body
+	}
+	if _, ok := query["query"]; ok {
+		for k, v := range query {
+			body[k] = v
+		}
+		return body
+	}
+	body["query"] = query
+	return body
+}
+
+func decodeByQueryResult(ctx context.Context, res *esapi.Response) (*ByQueryResult, error) {
+	var result ByQueryResult
+	if err := decode(ctx, res.Body, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Failures) > 0 {
+		return &result, &ByQueryError{Result: &result}
+	}
+	return &result, nil
+}
+
+func decodeTaskId(ctx context.Context, res *esapi.Response) (string, error) {
+	var r map[string]interface{}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return "", err
+	}
+	task, _ := r["task"].(string)
+	return task, nil
+}
+
+func GetTask(ctx context.Context, es *elasticsearch.Client, taskId string) (*TaskStatus, error) {
+	req := esapi.TasksGetRequest{
+		TaskID: taskId,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		Completed bool `json:"completed"`
+		Task      struct {
+			Description string         `json:"description"`
+			Cancelled   bool           `json:"cancelled"`
+			Status      *ByQueryResult `json:"status"`
+		} `json:"task"`
+		Response *ByQueryResult         `json:"response"`
+		Error    map[string]interface{} `json:"error"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	return &TaskStatus{Completed: r.Completed, Status: r.Task.Status, Description: r.Task.Description, Cancelled: r.Task.Cancelled, Response: r.Response, Error: r.Error}, nil
+}
+
+// WaitForTask polls the task at every interval until it is completed, reporting the progress to the optional callback.
+// If the completed task failed, the status is returned with the error of the task, or with a *ByQueryError if its response has failures.
+// It returns an error without polling if the interval is not positive.
+func WaitForTask(ctx context.Context, es *elasticsearch.Client, taskId string, interval time.Duration, options ...func(TaskStatus)) (*TaskStatus, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s to wait for the task %s", interval, taskId)
+	}
+	var progress func(TaskStatus)
+	if len(options) > 0 {
+		progress = options[0]
+	}
+	for {
+		status, err := GetTask(ctx, es, taskId)
+		if err != nil {
+			return nil, err
+		}
+		if status == nil {
+			return nil, errors.New("task not found")
+		}
+		if progress != nil {
+			progress(*status)
+		}
+		if status.Completed {
+			if status.Error != nil {
+				return status, fmt.Errorf("task %s failed: %v", taskId, status.Error)
+			}
+			if status.Response != nil && len(status.Response.Failures) > 0 {
+				return status, &ByQueryError{Result: status.Response}
+			}
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func CancelTask(ctx context.Context, es *elasticsearch.Client, taskId string) (bool, error) {
+	req := esapi.TasksCancelRequest{
+		TaskID: taskId,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return true, nil
+}
+
+// RethrottleUpdateByQuery changes the requests per second of a running update by query task, -1 to disable throttling.
+func RethrottleUpdateByQuery(ctx context.Context, es *elasticsearch.Client, taskId string, requestsPerSecond int) error {
+	req := esapi.UpdateByQueryRethrottleRequest{
+		TaskID:            taskId,
+		RequestsPerSecond: &requestsPerSecond,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return errors.New("response error")
+	}
+	return nil
+}
+
+// RethrottleDeleteByQuery changes the requests per second of a running delete by query task, -1 to disable throttling.
+func RethrottleDeleteByQuery(ctx context.Context, es *elasticsearch.Client, taskId string, requestsPerSecond int) error {
+	req := esapi.DeleteByQueryRethrottleRequest{
+		TaskID:            taskId,
+		RequestsPerSecond: &requestsPerSecond,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return errors.New("response error")
+	}
+	return nil
+}
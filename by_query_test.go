@@ -0,0 +1,49 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWaitForTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		byQuery  bool
+		failed   bool
+	}{
+		{"completed", `{"completed":true,"task":{"status":{"total":2,"updated":2}},"response":{"total":2,"updated":2}}`, false, false},
+		{"failures", `{"completed":true,"task":{},"response":{"total":2,"updated":1,"failures":[{"id":"2","cause":{"type":"version_conflict_engine_exception"}}]}}`, true, true},
+		{"error", `{"completed":true,"task":{},"error":{"type":"search_phase_execution_exception","reason":"all shards failed"}}`, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &fakeTransport{respond: func(req *http.Request) (int, string) {
+				return http.StatusOK, tt.response
+			}}
+			status, err := WaitForTask(context.Background(), newTestClient(t, transport), "node:1", 1)
+			if status == nil || !status.Completed {
+				t.Fatalf("status %v, expected a completed task", status)
+			}
+			if (err != nil) != tt.failed {
+				t.Fatalf("error %v, expected failed %v", err, tt.failed)
+			}
+			var byQueryError *ByQueryError
+			if errors.As(err, &byQueryError) != tt.byQuery {
+				t.Errorf("error %T, expected *ByQueryError %v", err, tt.byQuery)
+			}
+		})
+	}
+}
+
+func TestWaitForTaskInterval(t *testing.T) {
+	transport := &fakeTransport{}
+	if _, err := WaitForTask(context.Background(), newTestClient(t, transport), "node:1", 0); err == nil {
+		t.Error("expected an error for an interval which is not positive")
+	}
+	if len(transport.requests) != 0 {
+		t.Errorf("requests %v, expected no request", transport.requests)
+	}
+}
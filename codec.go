@@ -0,0 +1,78 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+type codecKey struct{}
+
+// Codec encodes the request bodies and the documents, and decodes the responses. DefaultCodec uses encoding/json; a faster library can be plugged globally by setting DefaultCodec,
+// per wrapper with the Codec field of Loader, Writer or SearchBuilder, or per call with WithCodec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+type JsonCodec struct{}
+
+func (c JsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+func (c JsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+func (c JsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+var DefaultCodec Codec = JsonCodec{}
+
+// WithCodec returns a context which makes the functions of this package use the codec for this call.
+func WithCodec(ctx context.Context, codec Codec) context.Context {
+	return context.WithValue(ctx, codecKey{}, codec)
+}
+
+func CodecFromContext(ctx context.Context) Codec {
+	if ctx != nil {
+		if codec, ok := ctx.Value(codecKey{}).(Codec); ok && codec != nil {
+			return codec
+		}
+	}
+	return DefaultCodec
+}
+
+// withDefaultCodec sets the codec of a wrapper in the context, unless the context already has a codec.
+func withDefaultCodec(ctx context.Context, codec Codec) context.Context {
+	if codec == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(codecKey{}).(Codec); ok {
+		return ctx
+	}
+	return WithCodec(ctx, codec)
+}
+
+// NewReader encodes v with the codec of the context, for the body of a request.
+func NewReader(ctx context.Context, v interface{}) io.Reader {
+	data, err := CodecFromContext(ctx).Marshal(v)
+	if err != nil {
+		return errorReader{err: err}
+	}
+	return bytes.NewReader(data)
+}
+
+func decode(ctx context.Context, r io.Reader, v interface{}) error {
+	return CodecFromContext(ctx).Decode(r, v)
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
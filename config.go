@@ -14,20 +14,21 @@ type TransportConfig struct {
 	Timeout               *int64 `mapstructure:"timeout" json:"timeout,omitempty" gorm:"column:timeout" bson:"timeout,omitempty" dynamodbav:"timeout,omitempty" firestore:"timeout,omitempty"`
 }
 type Config struct {
-	Addresses             []string        `mapstructure:"addresses" json:"addresses,omitempty" gorm:"column:addresses" bson:"addresses,omitempty" dynamodbav:"addresses,omitempty" firestore:"addresses,omitempty"`
-	Username              *string         `mapstructure:"username" json:"username,omitempty" gorm:"column:username" bson:"username,omitempty" dynamodbav:"username,omitempty" firestore:"username,omitempty"`
-	Password              *string         `mapstructure:"password" json:"password,omitempty" gorm:"column:password" bson:"password,omitempty" dynamodbav:"password,omitempty" firestore:"password,omitempty"`
-	CloudID               *string         `mapstructure:"cloud_id" json:"cloudID,omitempty" gorm:"column:cloudid" bson:"cloudID,omitempty" dynamodbav:"cloudID,omitempty" firestore:"cloudID,omitempty"`
-	APIKey                *string         `mapstructure:"api_key" json:"apiKey,omitempty" gorm:"column:apikey" bson:"apiKey,omitempty" dynamodbav:"apiKey,omitempty" firestore:"apiKey,omitempty"`
-	DisableRetry          *bool           `mapstructure:"disable_retry" json:"disableRetry,omitempty" gorm:"column:disableretry" bson:"disableRetry,omitempty" dynamodbav:"disableRetry,omitempty" firestore:"disableRetry,omitempty"`
-	EnableRetryOnTimeout  *bool           `mapstructure:"enableRetryOnTimeout" json:"enableRetryOnTimeout,omitempty" gorm:"column:enableretryontimeout" bson:"enableRetryOnTimeout,omitempty" dynamodbav:"enableRetryOnTimeout,omitempty" firestore:"enableRetryOnTimeout,omitempty"`
-	MaxRetries            *int            `mapstructure:"max_retries" json:"maxRetries,omitempty" gorm:"column:maxretries" bson:"maxRetries,omitempty" dynamodbav:"maxRetries,omitempty" firestore:"maxRetries,omitempty"`
-	DiscoverNodesOnStart  *bool           `mapstructure:"discover_nodes_on_start" json:"discoverNodesOnStart,omitempty" gorm:"column:discovernodesonstart" bson:"discoverNodesOnStart,omitempty" dynamodbav:"discoverNodesOnStart,omitempty" firestore:"discoverNodesOnStart,omitempty"`
-	DiscoverNodesInterval *int64          `mapstructure:"discover_nodes_interval" json:"discoverNodesInterval,omitempty" gorm:"column:discovernodesinterval" bson:"discoverNodesInterval,omitempty" dynamodbav:"discoverNodesInterval,omitempty" firestore:"discoverNodesInterval,omitempty"`
-	EnableMetrics         *bool           `mapstructure:"enable_metrics" json:"enableMetrics,omitempty" gorm:"column:enablemetrics" bson:"enableMetrics,omitempty" dynamodbav:"enableMetrics,omitempty" firestore:"enableMetrics,omitempty"`
-	EnableDebugLogger     *bool           `mapstructure:"enable_debug_logger" json:"enableDebugLogger,omitempty" gorm:"column:enableDebugLogger" bson:"enableDebugLogger,omitempty" dynamodbav:"enableDebugLogger,omitempty" firestore:"enableDebugLogger,omitempty"`
-	DisableMetaHeader     *bool           `mapstructure:"disable_meta_header" json:"disableMetaHeader,omitempty" gorm:"column:disablemetaheader" bson:"disableMetaHeader,omitempty" dynamodbav:"disableMetaHeader,omitempty" firestore:"disableMetaHeader,omitempty"`
-	Transport             TransportConfig `mapstructure:"transport" json:"transport,omitempty" gorm:"column:transport" bson:"transport,omitempty" dynamodbav:"transport,omitempty" firestore:"transport,omitempty"`
+	Addresses               []string        `mapstructure:"addresses" json:"addresses,omitempty" gorm:"column:addresses" bson:"addresses,omitempty" dynamodbav:"addresses,omitempty" firestore:"addresses,omitempty"`
+	Username                *string         `mapstructure:"username" json:"username,omitempty" gorm:"column:username" bson:"username,omitempty" dynamodbav:"username,omitempty" firestore:"username,omitempty"`
+	Password                *string         `mapstructure:"password" json:"password,omitempty" gorm:"column:password" bson:"password,omitempty" dynamodbav:"password,omitempty" firestore:"password,omitempty"`
+	CloudID                 *string         `mapstructure:"cloud_id" json:"cloudID,omitempty" gorm:"column:cloudid" bson:"cloudID,omitempty" dynamodbav:"cloudID,omitempty" firestore:"cloudID,omitempty"`
+	APIKey                  *string         `mapstructure:"api_key" json:"apiKey,omitempty" gorm:"column:apikey" bson:"apiKey,omitempty" dynamodbav:"apiKey,omitempty" firestore:"apiKey,omitempty"`
+	DisableRetry            *bool           `mapstructure:"disable_retry" json:"disableRetry,omitempty" gorm:"column:disableretry" bson:"disableRetry,omitempty" dynamodbav:"disableRetry,omitempty" firestore:"disableRetry,omitempty"`
+	EnableRetryOnTimeout    *bool           `mapstructure:"enableRetryOnTimeout" json:"enableRetryOnTimeout,omitempty" gorm:"column:enableretryontimeout" bson:"enableRetryOnTimeout,omitempty" dynamodbav:"enableRetryOnTimeout,omitempty" firestore:"enableRetryOnTimeout,omitempty"`
+	MaxRetries              *int            `mapstructure:"max_retries" json:"maxRetries,omitempty" gorm:"column:maxretries" bson:"maxRetries,omitempty" dynamodbav:"maxRetries,omitempty" firestore:"maxRetries,omitempty"`
+	DiscoverNodesOnStart    *bool           `mapstructure:"discover_nodes_on_start" json:"discoverNodesOnStart,omitempty" gorm:"column:discovernodesonstart" bson:"discoverNodesOnStart,omitempty" dynamodbav:"discoverNodesOnStart,omitempty" firestore:"discoverNodesOnStart,omitempty"`
+	DiscoverNodesInterval   *int64          `mapstructure:"discover_nodes_interval" json:"discoverNodesInterval,omitempty" gorm:"column:discovernodesinterval" bson:"discoverNodesInterval,omitempty" dynamodbav:"discoverNodesInterval,omitempty" firestore:"discoverNodesInterval,omitempty"`
+	EnableMetrics           *bool           `mapstructure:"enable_metrics" json:"enableMetrics,omitempty" gorm:"column:enablemetrics" bson:"enableMetrics,omitempty" dynamodbav:"enableMetrics,omitempty" firestore:"enableMetrics,omitempty"`
+	EnableDebugLogger       *bool           `mapstructure:"enable_debug_logger" json:"enableDebugLogger,omitempty" gorm:"column:enableDebugLogger" bson:"enableDebugLogger,omitempty" dynamodbav:"enableDebugLogger,omitempty" firestore:"enableDebugLogger,omitempty"`
+	DisableMetaHeader       *bool           `mapstructure:"disable_meta_header" json:"disableMetaHeader,omitempty" gorm:"column:disablemetaheader" bson:"disableMetaHeader,omitempty" dynamodbav:"disableMetaHeader,omitempty" firestore:"disableMetaHeader,omitempty"`
+	EnableCompatibilityMode *bool           `mapstructure:"enable_compatibility_mode" json:"enableCompatibilityMode,omitempty" gorm:"column:enablecompatibilitymode" bson:"enableCompatibilityMode,omitempty" dynamodbav:"enableCompatibilityMode,omitempty" firestore:"enableCompatibilityMode,omitempty"`
+	Transport               TransportConfig `mapstructure:"transport" json:"transport,omitempty" gorm:"column:transport" bson:"transport,omitempty" dynamodbav:"transport,omitempty" firestore:"transport,omitempty"`
 }
 
 func GetConfig(conf Config, timeouts ...time.Duration) elasticsearch.Config {
@@ -89,5 +90,8 @@ func GetConfig(conf Config, timeouts ...time.Duration) elasticsearch.Config {
 	if conf.DisableMetaHeader != nil {
 		c.DisableMetaHeader = *conf.DisableMetaHeader
 	}
+	if conf.EnableCompatibilityMode != nil {
+		c.EnableCompatibilityMode = *conf.EnableCompatibilityMode
+	}
 	return c
 }
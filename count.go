@@ -0,0 +1,65 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// Count returns the number of documents matching the query. The query is the body of a search request or only its "query" clause; the other clauses are ignored.
+func Count(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}) (int64, error) {
+	return CountWithIndices(ctx, es, []string{indexName}, query)
+}
+
+func CountWithIndices(ctx context.Context, es *elasticsearch.Client, indices []string, query map[string]interface{}) (int64, error) {
+	body := map[string]interface{}{"query": BuildQueryBody(query)["query"]}
+	req := esapi.CountRequest{
+		Index: pathIndices(indices...),
+		Body:  NewReader(ctx, body),
+	}
+	if len(indices) > 1 {
+		ignoreUnavailable := true
+		req.IgnoreUnavailable = &ignoreUnavailable
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return -1, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return -1, errors.New("response error")
+	}
+	var r map[string]interface{}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return -1, err
+	}
+	count, _ := r["count"].(float64)
+	return int64(count), nil
+}
+
+// ExistsByQuery checks if any document matches the query. The search stops at the first matching document of each shard.
+func ExistsByQuery(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}) (bool, error) {
+	body := map[string]interface{}{"query": BuildQueryBody(query)["query"]}
+	size := 0
+	terminateAfter := 1
+	req := esapi.SearchRequest{
+		Index:          pathIndices(indexName),
+		Body:           NewReader(ctx, body),
+		Size:           &size,
+		TerminateAfter: &terminateAfter,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	var r SearchResponse
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return false, err
+	}
+	return r.Hits.Total.Value > 0, nil
+}
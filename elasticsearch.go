@@ -2,12 +2,11 @@ package elasticsearch
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
-	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"log"
+	"net/http"
 	"reflect"
 	"strings"
 	"time"
@@ -21,95 +20,86 @@ func Connect(config Config, timeouts ...time.Duration) (*elasticsearch.Client, e
 func FindIdField(modelType reflect.Type) (int, string, string) {
 	return FindBsonField(modelType, "_id")
 }
+
+// FindBsonField returns the index, the name and the json name of the field with the bson name, or -1 if there is no such field.
+// The promoted fields of embedded structs are found too, with the index of their embedded struct; use GetStructFields for their index sequence.
 func FindBsonField(modelType reflect.Type, bsonName string) (int, string, string) {
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		field := modelType.Field(i)
-		bsonTag := field.Tag.Get("bson")
-		tags := strings.Split(bsonTag, ",")
-		json := field.Name
-		if tag1, ok1 := field.Tag.Lookup("json"); ok1 {
-			json = strings.Split(tag1, ",")[0]
-		}
+	if field, ok := findBsonField(modelType, bsonName); ok {
+		return topIndex(field), field.Name, field.JsonName
+	}
+	return -1, "", ""
+}
+func findBsonField(modelType reflect.Type, bsonName string) (StructField, bool) {
+	for _, field := range GetStructFields(modelType) {
+		tags := strings.Split(field.Tag.Get("bson"), ",")
 		for _, tag := range tags {
 			if strings.TrimSpace(tag) == bsonName {
-				return i, field.Name, json
+				return field, true
 			}
 		}
 	}
-	return -1, "", ""
+	return StructField{}, false
 }
+// FindFieldByName returns the index and the json name of the field, or -1 if there is no such field. A promoted field of an embedded struct has the index of its embedded struct.
 func FindFieldByName(modelType reflect.Type, fieldName string) (index int, jsonTagName string) {
-	numField := modelType.NumField()
-	for index := 0; index < numField; index++ {
-		field := modelType.Field(index)
-		if field.Name == fieldName {
-			jsonTagName := fieldName
-			if jsonTag, ok := field.Tag.Lookup("json"); ok {
-				jsonTagName = strings.Split(jsonTag, ",")[0]
-			}
-			return index, jsonTagName
-		}
+	fields := getStructFields(modelType)
+	if i, ok := fields.byName[fieldName]; ok {
+		return topIndex(fields.list[i]), fields.list[i].JsonName
 	}
 	return -1, fieldName
 }
 
+// FindFieldByJson returns the index and the name of the field with the json name, or with the json path such as "address.city" for a field of a nested struct, or -1 if there is no such field.
+// A promoted field of an embedded struct has the index of its embedded struct, and a field of a nested struct the index of the top level field of the path.
 func FindFieldByJson(modelType reflect.Type, jsonTagName string) (index int, fieldName string) {
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		field := modelType.Field(i)
-		tag1, ok1 := field.Tag.Lookup("json")
-		if ok1 && strings.Split(tag1, ",")[0] == jsonTagName {
-			return i, field.Name
-		}
+	if field, ok := FindStructField(modelType, jsonTagName); ok {
+		return topIndex(field), field.Name
 	}
 	return -1, jsonTagName
 }
 
 func FindFieldByIndex(modelType reflect.Type, fieldIndex int) (fieldName, jsonTagName string) {
 	if fieldIndex < modelType.NumField() {
-		field := modelType.Field(fieldIndex)
-		jsonTagName := ""
-		if jsonTag, ok := field.Tag.Lookup("json"); ok {
-			jsonTagName = strings.Split(jsonTag, ",")[0]
+		for _, field := range GetStructFields(modelType) {
+			if len(field.Index) == 1 && field.Index[0] == fieldIndex {
+				return field.Name, field.JsonName
+			}
 		}
-		return field.Name, jsonTagName
+		return modelType.Field(fieldIndex).Name, ""
 	}
 	return "", ""
 }
 
+// MakeMapJson maps the names of the fields to their json names, including the promoted fields of embedded structs, and the dotted paths of the fields of nested structs such as "Address.City" to "address.city".
 func MakeMapJson(modelType reflect.Type) map[string]string {
 	maps := make(map[string]string)
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		key1 := modelType.Field(i).Name
-		fields, _ := modelType.FieldByName(key1)
-		if tag, ok := fields.Tag.Lookup("json"); ok {
-			if strings.Contains(tag, ",") {
-				a := strings.Split(tag, ",")
-				maps[key1] = a[0]
-			} else {
-				maps[key1] = tag
-			}
-		} else {
-			maps[key1] = key1
+	makeMapJson(modelType, "", "", maps, make(map[reflect.Type]bool))
+	return maps
+}
+func makeMapJson(modelType reflect.Type, prefix string, jsonPrefix string, maps map[string]string, visited map[reflect.Type]bool) {
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if visited[modelType] {
+		return
+	}
+	visited[modelType] = true
+	for _, field := range GetStructFields(modelType) {
+		maps[prefix+field.Name] = jsonPrefix + field.JsonName
+		t := field.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct {
+			makeMapJson(t, prefix+field.Name+".", jsonPrefix+field.JsonName+".", maps, visited)
 		}
 	}
-	return maps
+	delete(visited, modelType)
 }
 
 //For Insert
 func BuildQueryWithoutIdFromObject(object interface{}) map[string]interface{} {
-	valueOf := reflect.Indirect(reflect.ValueOf(object))
-	idIndex, _, _ := FindIdField(valueOf.Type())
-	result := map[string]interface{}{}
-	for i := 0; i < valueOf.NumField(); i++ {
-		if i != idIndex {
-			_, jsonName := FindFieldByIndex(valueOf.Type(), i)
-			result[jsonName] = valueOf.Field(i).Interface()
-		}
-	}
-	return result
+	return GetMetadata(reflect.TypeOf(object)).Body(object)
 }
 
 func BuildQueryMap(indexName string, query map[string]interface{}) map[string]interface{} {
@@ -140,7 +130,7 @@ func Exist(ctx context.Context, es *elasticsearch.Client, indexName string, docu
 		return false, errors.New("response error")
 	} else {
 		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return false, err
 		} else {
 			return r["found"].(bool), nil
@@ -148,20 +138,25 @@ func Exist(ctx context.Context, es *elasticsearch.Client, indexName string, docu
 	}
 }
 
-func FindOneById(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, modelType reflect.Type) (interface{}, error) {
+func FindOneById(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, modelType reflect.Type, options ...Projection) (interface{}, error) {
 	result := reflect.New(modelType).Interface()
-	if ok, err := FindOneByIdAndDecode(ctx, es, indexName, documentID, result); ok {
+	if ok, err := FindOneByIdAndDecode(ctx, es, indexName, documentID, result, options...); ok {
 		return result, nil
 	} else {
 		return nil, err
 	}
 }
 
-func FindOneByIdAndDecode(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, result interface{}) (bool, error) {
+func FindOneByIdAndDecode(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, result interface{}, options ...Projection) (bool, error) {
 	req := esapi.GetRequest{
 		Index:      indexName,
 		DocumentID: documentID,
 	}
+	if p := getProjection(options); p != nil {
+		req.SourceIncludes = p.Includes
+		req.SourceExcludes = p.Excludes
+		req.StoredFields = p.StoredFields
+	}
 	res, err := req.Do(ctx, es)
 	if err != nil {
 		return false, err
@@ -171,11 +166,14 @@ func FindOneByIdAndDecode(ctx context.Context, es *elasticsearch.Client, indexNa
 	if res.IsError() {
 		return false, errors.New("response error")
 	} else {
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		var r GetResponse
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return false, err
 		} else {
-			if err := json.NewDecoder(esutil.NewJSONReader(r["_source"])).Decode(&result); err != nil {
+			if err := decodeSource(CodecFromContext(ctx), r.Id, r.Source, r.Fields, result); err != nil {
+				return false, err
+			}
+			if err := DecryptModel(ctx, result); err != nil {
 				return false, err
 			}
 			return true, nil
@@ -183,22 +181,52 @@ func FindOneByIdAndDecode(ctx context.Context, es *elasticsearch.Client, indexNa
 	}
 }
 
-func FindOne(ctx context.Context, es *elasticsearch.Client, index []string, query map[string]interface{}, modelType reflect.Type) (interface{}, error) {
+// FindIndexById returns the name of the concrete index containing the document, when indexName is an alias or a wildcard such as "events-*".
+func FindIndexById(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string) (string, error) {
+	size := 1
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"ids": map[string]interface{}{"values": []string{documentID}}},
+	}
+	req := esapi.SearchRequest{
+		Index: pathIndices(indexName),
+		Body:  NewReader(ctx, query),
+		Size:  &size,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", errors.New("response error")
+	}
+	var r SearchResponse
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return "", err
+	}
+	if len(r.Hits.Hits) == 0 {
+		return "", nil
+	}
+	return r.Hits.Hits[0].Index, nil
+}
+
+func FindOne(ctx context.Context, es *elasticsearch.Client, index []string, query map[string]interface{}, modelType reflect.Type, options ...Projection) (interface{}, error) {
 	result := reflect.New(modelType).Interface()
-	if ok, err := FindOneAndDecode(ctx, es, index, query, result); ok {
+	if ok, err := FindOneAndDecode(ctx, es, index, query, result, options...); ok {
 		return result, nil
 	} else {
 		return nil, err
 	}
 }
 
-func FindOneAndDecode(ctx context.Context, es *elasticsearch.Client, index []string, query map[string]interface{}, result interface{}) (bool, error) {
+func FindOneAndDecode(ctx context.Context, es *elasticsearch.Client, index []string, query map[string]interface{}, result interface{}, options ...Projection) (bool, error) {
 	req := esapi.SearchRequest{
-		Index:          index,
-		Body:           esutil.NewJSONReader(query),
+		Index:          pathIndices(index...),
+		Body:           NewReader(ctx, query),
 		TrackTotalHits: true,
 		Pretty:         true,
 	}
+	ApplyProjection(&req, getProjection(options))
 	res, err := req.Do(ctx, es)
 	if err != nil {
 		return false, err
@@ -208,14 +236,12 @@ func FindOneAndDecode(ctx context.Context, es *elasticsearch.Client, index []str
 	if res.IsError() {
 		return false, errors.New("response error")
 	} else {
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		var r SearchResponse
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return false, err
 		} else {
-			hits := r["hits"].(map[string]interface{})["hits"].([]interface{})
-			total := int(r["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
-			if total >= 1 {
-				if err := json.NewDecoder(esutil.NewJSONReader(hits[0])).Decode(&result); err != nil {
+			if len(r.Hits.Hits) >= 1 {
+				if err := DecodeSearchHit(ctx, r.Hits.Hits[0], result); err != nil {
 					return false, err
 				}
 				return true, nil
@@ -225,23 +251,24 @@ func FindOneAndDecode(ctx context.Context, es *elasticsearch.Client, index []str
 	}
 }
 
-func Find(ctx context.Context, es *elasticsearch.Client, indexName []string, query map[string]interface{}, modelType reflect.Type) (interface{}, error) {
+func Find(ctx context.Context, es *elasticsearch.Client, indexName []string, query map[string]interface{}, modelType reflect.Type, options ...Projection) (interface{}, error) {
 	modelsType := reflect.Zero(reflect.SliceOf(modelType)).Type()
 	result := reflect.New(modelsType).Interface()
-	if ok, err := FindAndDecode(ctx, es, indexName, query, result); ok {
+	if ok, err := FindAndDecode(ctx, es, indexName, query, result, options...); ok {
 		return result, nil
 	} else {
 		return nil, err
 	}
 }
 
-func FindAndDecode(ctx context.Context, es *elasticsearch.Client, indexName []string, query map[string]interface{}, result interface{}) (bool, error) {
+func FindAndDecode(ctx context.Context, es *elasticsearch.Client, indexName []string, query map[string]interface{}, result interface{}, options ...Projection) (bool, error) {
 	req := esapi.SearchRequest{
-		Index:          indexName,
-		Body:           esutil.NewJSONReader(query),
+		Index:          pathIndices(indexName...),
+		Body:           NewReader(ctx, query),
 		TrackTotalHits: true,
 		Pretty:         true,
 	}
+	ApplyProjection(&req, getProjection(options))
 	res, err := req.Do(ctx, es)
 	if err != nil {
 		return false, err
@@ -251,12 +278,11 @@ func FindAndDecode(ctx context.Context, es *elasticsearch.Client, indexName []st
 	if res.IsError() {
 		return false, errors.New("response error")
 	} else {
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		var r SearchResponse
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return false, err
 		} else {
-			hits := r["hits"].(map[string]interface{})["hits"].([]interface{})
-			if err := json.NewDecoder(esutil.NewJSONReader(hits)).Decode(&result); err != nil {
+			if err := DecodeSearchHits(ctx, r.Hits.Hits, result); err != nil {
 				return false, err
 			}
 			return true, nil
@@ -266,16 +292,14 @@ func FindAndDecode(ctx context.Context, es *elasticsearch.Client, indexName []st
 
 func FindValueByJson(model interface{}, jsonTagName string) (index int, fieldName string, val string) {
 	object := reflect.Indirect(reflect.ValueOf(model))
-	modelType := reflect.TypeOf(object)
-
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		val := object.Field(i)
-		field := modelType.Field(i)
-		tag1, ok1 := field.Tag.Lookup("json")
-		if ok1 && strings.Split(tag1, ",")[0] == jsonTagName {
-			return i, field.Name, val.String()
+	if object.Kind() != reflect.Struct {
+		return -1, jsonTagName, ""
+	}
+	if field, ok := FindStructField(object.Type(), jsonTagName); ok {
+		if v, ok := FieldByIndex(object, field.Index); ok {
+			return topIndex(field), field.Name, v.String()
 		}
+		return topIndex(field), field.Name, ""
 	}
 	return -1, jsonTagName, ""
 }
@@ -284,97 +308,151 @@ func FindListIdField(modelType reflect.Type, model interface{}) (listIdS []inter
 	value := reflect.Indirect(reflect.ValueOf(model))
 
 	if value.Kind() == reflect.Slice {
+		meta := GetMetadata(modelType)
+		if len(meta.Id) == 0 {
+			return
+		}
+		listIdS = make([]interface{}, 0, value.Len())
 		for i := 0; i < value.Len(); i++ {
-			sliceValue := value.Index(i).Interface()
-			if idIndex, _, _ := FindIdField(modelType); idIndex >= 0 {
-				modelValue := reflect.Indirect(reflect.ValueOf(sliceValue))
-				idValue := modelValue.Field(idIndex).String()
-				listIdS = append(listIdS, idValue)
-			}
+			listIdS = append(listIdS, meta.GetId(value.Index(i).Interface()))
 		}
 	}
 	return
 }
 
-func InsertOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}) (int64, error) {
-	var req esapi.CreateRequest
-	if idIndex, _, _ := FindIdField(modelType); idIndex >= 0 {
-		modelValue := reflect.Indirect(reflect.ValueOf(model))
-		idValue := modelValue.Field(idIndex).String()
-		body := BuildQueryWithoutIdFromObject(model)
+// InsertOne creates the document. The optional parameter is the ingest pipeline to preprocess the document.
+// The model may be the result of a mapping of a model of modelType, such as a map.
+func InsertOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}, options ...string) (int64, error) {
+	return insertOne(ctx, es, indexName, modelType, documentId(modelType, model), model, options...)
+}
+
+// insertOne creates the document of the id, which is taken from the model before it is mapped by a Mapper.
+func insertOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, idValue string, model interface{}, options ...string) (int64, error) {
+	r, _, err := createOne(ctx, es, indexName, modelType, idValue, model, options...)
+	return r, err
+}
+
+// createOne creates the document and returns its version and its id, generated by Elasticsearch if idValue is empty.
+func createOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, idValue string, model interface{}, options ...string) (int64, string, error) {
+	var body interface{} = model
+	meta := GetMetadata(modelType)
+	if len(meta.Id) > 0 || len(meta.encrypted) > 0 {
+		doc := documentBody(modelType, model)
+		if err := EncryptBody(ctx, modelType, doc); err != nil {
+			return -1, "", err
+		}
+		body = doc
+	}
+	var pipeline string
+	if len(options) > 0 {
+		pipeline = options[0]
+	}
+	var req esapi.Request
+	if len(idValue) > 0 {
 		req = esapi.CreateRequest{
 			Index:      indexName,
 			DocumentID: idValue,
-			Body:       esutil.NewJSONReader(body),
+			Body:       NewReader(ctx, body),
 			Refresh:    "true",
+			Pipeline:   pipeline,
 		}
 	} else {
-		req = esapi.CreateRequest{
-			Index:   indexName,
-			Body:    esutil.NewJSONReader(model),
-			Refresh: "true",
+		req = esapi.IndexRequest{
+			Index:    indexName,
+			Body:     NewReader(ctx, body),
+			OpType:   "create",
+			Refresh:  "true",
+			Pipeline: pipeline,
 		}
 	}
 	res, err := req.Do(ctx, es)
 	if err != nil {
-		return -1, err
+		return -1, "", err
 	}
 	defer res.Body.Close()
 	if res.IsError() {
-		return 0, nil
+		return 0, "", nil
 	} else {
 		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			return -1, err
+		if err := decode(ctx, res.Body, &r); err != nil {
+			return -1, "", err
 		} else {
 			log.Printf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
-			return int64(r["_version"].(float64)), nil
+			id, _ := r["_id"].(string)
+			return int64(r["_version"].(float64)), id, nil
 		}
 	}
 }
 
+// BuildIndicesResult returns, for the ids of listIds in order, their positions in successIds and in failIds, as the baseline did, with a map of the positions instead of nested loops.
 func BuildIndicesResult(listIds, successIds, failIds []interface{}) (successIndices, failureIndices []int) {
 	if len(listIds) > 0 {
+		success := positions(successIds)
+		failure := positions(failIds)
 		for _, idValue := range listIds {
-			for index, id := range successIds {
-				if id == idValue {
-					successIndices = append(successIndices, int(index))
-				}
-			}
-			for index, id := range failIds {
-				if id == idValue {
-					failureIndices = append(failureIndices, int(index))
-				}
-			}
+			successIndices = append(successIndices, success[idValue]...)
+			failureIndices = append(failureIndices, failure[idValue]...)
 		}
 	}
 	return
 }
 
+func positions(ids []interface{}) map[interface{}][]int {
+	m := make(map[interface{}][]int, len(ids))
+	for index, id := range ids {
+		m[id] = append(m[id], index)
+	}
+	return m
+}
+
 func UpdateOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, model interface{}) (int64, error) {
-	idIndex, _, _ := FindIdField(modelType)
-	if idIndex < 0 {
+	if len(GetMetadata(modelType).Id) == 0 {
 		return 0, errors.New("missing document ID in the object")
 	}
-	modelValue := reflect.ValueOf(model)
-	idValue := modelValue.Field(idIndex).String()
-	body := BuildQueryWithoutIdFromObject(model)
+	var created []string
+	if model != nil {
+		created = GetMetadata(reflect.TypeOf(model)).emptyCreated(model)
+	}
+	return updateOne(ctx, es, indexName, documentId(modelType, model), modelType, model, created)
+}
+
+// updateOne updates the document of the id, which is taken from the model before it is mapped by a Mapper, without the fields named in created, the createdAt and createdBy which were empty in the model.
+// If the context has an expected version, the document is updated only if its stored version is this version.
+func updateOne(ctx context.Context, es *elasticsearch.Client, indexName string, idValue string, modelType reflect.Type, model interface{}, created []string) (int64, error) {
+	body := documentBody(modelType, model)
+	for _, name := range created {
+		delete(body, name)
+	}
+	if err := EncryptBody(ctx, modelType, body); err != nil {
+		return -1, err
+	}
 	req := esapi.UpdateRequest{
 		Index:      indexName,
 		DocumentID: idValue,
-		Body:       esutil.NewJSONReader(body),
+		Body:       NewReader(ctx, map[string]interface{}{"doc": body}),
 		Refresh:    "true",
 	}
+	expected := getExpectedVersion(ctx)
+	if expected != nil {
+		seqNo, primaryTerm, err := checkVersion(ctx, es, indexName, idValue, expected)
+		if err != nil {
+			return -1, err
+		}
+		req.IfSeqNo, req.IfPrimaryTerm = &seqNo, &primaryTerm
+	}
 	res, err := req.Do(ctx, es)
 	if err != nil {
 		return -1, err
 	}
 	defer res.Body.Close()
+	if expected != nil && res.StatusCode == http.StatusConflict {
+		return -1, ErrVersionConflict
+	}
 	if res.IsError() {
 		return -1, errors.New("document ID not exists in the index")
 	} else {
 		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return -1, err
 		} else {
 			successful := int64(r["_shards"].(map[string]interface{})["successful"].(float64))
@@ -383,14 +461,26 @@ func UpdateOne(ctx context.Context, es *elasticsearch.Client, indexName string,
 	}
 }
 
-func UpsertOne(ctx context.Context, es *elasticsearch.Client, indexName string, id string, model interface{}) (int64, error) {
-	body := BuildQueryWithoutIdFromObject(model)
+// UpsertOne indexes the document. The optional parameter is the ingest pipeline to preprocess the document.
+func UpsertOne(ctx context.Context, es *elasticsearch.Client, indexName string, id string, model interface{}, options ...string) (int64, error) {
+	return upsertOne(ctx, es, indexName, reflect.TypeOf(model), id, model, options...)
+}
+
+// upsertOne indexes the document, which may be the result of a mapping of a model of modelType, such as a map.
+func upsertOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, id string, model interface{}, options ...string) (int64, error) {
+	body := documentBody(modelType, model)
+	if err := EncryptBody(ctx, modelType, body); err != nil {
+		return -1, err
+	}
 	req := esapi.IndexRequest{
 		Index:      indexName,
 		DocumentID: id,
-		Body:       esutil.NewJSONReader(body),
+		Body:       NewReader(ctx, body),
 		Refresh:    "true",
 	}
+	if len(options) > 0 && len(options[0]) > 0 {
+		req.Pipeline = options[0]
+	}
 	res, err := req.Do(ctx, es)
 	if err != nil {
 		return -1, err
@@ -400,7 +490,7 @@ func UpsertOne(ctx context.Context, es *elasticsearch.Client, indexName string,
 		return -1, errors.New("document ID not exists in the index")
 	}
 	var r map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+	if err := decode(ctx, res.Body, &r); err != nil {
 		return -1, err
 	}
 	successful := int64(r["_shards"].(map[string]interface{})["successful"].(float64))
@@ -416,7 +506,7 @@ func PatchOne(ctx context.Context, es *elasticsearch.Client, indexName string, m
 	req := esapi.UpdateRequest{
 		Index:      indexName,
 		DocumentID: idValue.String(),
-		Body:       esutil.NewJSONReader(model),
+		Body:       NewReader(ctx, map[string]interface{}{"doc": model}),
 		Refresh:    "true",
 	}
 	res, err := req.Do(ctx, es)
@@ -428,7 +518,7 @@ func PatchOne(ctx context.Context, es *elasticsearch.Client, indexName string, m
 		return -1, errors.New("document ID not exists in the index")
 	} else {
 		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return -1, err
 		} else {
 			successful := int64(r["_shards"].(map[string]interface{})["successful"].(float64))
@@ -451,7 +541,7 @@ func DeleteOne(ctx context.Context, es *elasticsearch.Client, indexName string,
 		return -1, errors.New("document ID not exists in the index")
 	} else {
 		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return -1, err
 		} else {
 			successful := int64(r["_shards"].(map[string]interface{})["successful"].(float64))
@@ -461,20 +551,17 @@ func DeleteOne(ctx context.Context, es *elasticsearch.Client, indexName string,
 }
 
 func GetFieldByJson(modelType reflect.Type, jsonName string) (int, string, string) {
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		field := modelType.Field(i)
-		tag1, ok1 := field.Tag.Lookup("json")
-		if ok1 && strings.Split(tag1, ",")[0] == jsonName {
-			if tag2, ok2 := field.Tag.Lookup("bson"); ok2 {
-				return i, field.Name, strings.Split(tag2, ",")[0]
-			}
-			return i, field.Name, ""
+	if field, ok := FindStructField(modelType, jsonName); ok {
+		if tag, ok := field.Tag.Lookup("bson"); ok {
+			return topIndex(field), field.Name, strings.Split(tag, ",")[0]
 		}
+		return topIndex(field), field.Name, ""
 	}
 	return -1, jsonName, jsonName
 }
 
+// MapModels maps each model of the slice in place: a model is passed by pointer, and the result of the mapping replaces the model if it is another model of the same type.
+// It stops at the first error of the mapping.
 func MapModels(ctx context.Context, models interface{}, mp func(context.Context, interface{}) (interface{}, error)) (interface{}, error) {
 	valueModelObject := reflect.Indirect(reflect.ValueOf(models))
 	if valueModelObject.Kind() == reflect.Ptr {
@@ -484,16 +571,47 @@ func MapModels(ctx context.Context, models interface{}, mp func(context.Context,
 		le := valueModelObject.Len()
 		for i := 0; i < le; i++ {
 			x := valueModelObject.Index(i)
-			k := x.Kind()
-			if k == reflect.Struct {
-				y := x.Addr().Interface()
-				mp(ctx, y)
+			var y interface{}
+			if x.Kind() == reflect.Struct {
+				y = x.Addr().Interface()
 			} else {
-				y := x.Interface()
-				mp(ctx, y)
+				y = x.Interface()
 			}
-
+			r, err := mp(ctx, y)
+			if err != nil {
+				return models, err
+			}
+			setMapped(x, r)
 		}
 	}
 	return models, nil
 }
+
+// MapToDbModels maps each model of the slice for a write, without changing the slice: it returns a new slice of the results of the mapping.
+func MapToDbModels(ctx context.Context, models interface{}, mp func(context.Context, interface{}) (interface{}, error)) ([]interface{}, error) {
+	value := reflect.Indirect(reflect.ValueOf(models))
+	if value.Kind() != reflect.Slice {
+		return nil, errors.New("models must be a slice")
+	}
+	result := make([]interface{}, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		r, err := mp(ctx, value.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+func setMapped(x reflect.Value, r interface{}) {
+	if r == nil || !x.CanSet() {
+		return
+	}
+	v := reflect.ValueOf(r)
+	if v.Type() == x.Type() {
+		x.Set(v)
+	} else if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Type() == x.Type() {
+		x.Set(v.Elem())
+	}
+}
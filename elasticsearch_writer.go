@@ -10,6 +10,7 @@ type ElasticSearchWriter struct {
 	client    *elasticsearch.Client
 	indexName string
 	Map       func(ctx context.Context, model interface{}) (interface{}, error)
+	Audit     *Audit
 }
 
 func NewElasticSearchWriter(client *elasticsearch.Client, indexName string, options ...func(context.Context, interface{}) (interface{}, error)) *ElasticSearchWriter {
@@ -21,6 +22,8 @@ func NewElasticSearchWriter(client *elasticsearch.Client, indexName string, opti
 }
 
 func (w *ElasticSearchWriter) Write(ctx context.Context, model interface{}) error {
+	ctx = withDefaultAudit(ctx, w.Audit)
+	model = SetAuditFields(ctx, model, true)
 	modelType := reflect.TypeOf(model)
 	_, _, id := FindValueByJson(modelType, "id")
 	if w.Map != nil {
@@ -28,7 +31,7 @@ func (w *ElasticSearchWriter) Write(ctx context.Context, model interface{}) erro
 		if er0 != nil {
 			return er0
 		}
-		_, er1 := UpsertOne(ctx, w.client, w.indexName, id, m2)
+		_, er1 := upsertOne(ctx, w.client, w.indexName, modelType, id, m2)
 		return er1
 	}
 	_, er2 := UpsertOne(ctx, w.client, w.indexName, id, model)
@@ -0,0 +1,627 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BlindIndexSuffix is the suffix of the field of the blind index of an encrypted field, such as "nationalId_bidx" for "nationalId".
+const BlindIndexSuffix = "_bidx"
+
+const encryptedPrefix = "enc:"
+
+type encryptorKey struct{}
+
+// KeyProvider provides the AES keys (16, 24 or 32 bytes) of the field encryption. The current key encrypts the new values;
+// the other keys are kept to decrypt the values encrypted before a rotation, which are encrypted again with the current key at their next write.
+type KeyProvider interface {
+	CurrentKeyId(ctx context.Context) (string, error)
+	Key(ctx context.Context, id string) ([]byte, error)
+	KeyIds(ctx context.Context) ([]string, error)
+}
+
+type StaticKeyProvider struct {
+	CurrentId string
+	Keys      map[string][]byte
+}
+
+func NewStaticKeyProvider(currentId string, keys map[string][]byte) *StaticKeyProvider {
+	return &StaticKeyProvider{CurrentId: currentId, Keys: keys}
+}
+
+func (p *StaticKeyProvider) CurrentKeyId(ctx context.Context) (string, error) {
+	return p.CurrentId, nil
+}
+func (p *StaticKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	if key, ok := p.Keys[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown encryption key '%s'", id)
+}
+func (p *StaticKeyProvider) KeyIds(ctx context.Context) ([]string, error) {
+	ids := make([]string, 0, len(p.Keys))
+	for id := range p.Keys {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Encryptor encrypts the string fields tagged es:",encrypted" with AES-GCM. The value is stored as "enc:<key id>:<base64 of nonce and ciphertext>".
+// A field tagged es:",encrypted,deterministic" is encrypted with a nonce derived from the value, so that a value has a single ciphertext per key and can be matched by equality;
+// a field tagged es:",encrypted,blindIndex" also stores the HMAC-SHA256 of the value by IndexKey in the field with BlindIndexSuffix, which is matched instead of the field.
+// The equality clauses of query.BuildBody on these fields are translated by EncryptQuery.
+type Encryptor struct {
+	Keys     KeyProvider
+	IndexKey []byte
+}
+
+func NewEncryptor(keys KeyProvider, indexKey []byte) *Encryptor {
+	return &Encryptor{Keys: keys, IndexKey: indexKey}
+}
+
+// DefaultEncryptor is used when the context has no encryptor; a model with encrypted fields cannot be written or read if both are nil.
+var DefaultEncryptor *Encryptor
+
+// WithEncryptor returns a context which makes the functions of this package use the encryptor for this call.
+func WithEncryptor(ctx context.Context, encryptor *Encryptor) context.Context {
+	return context.WithValue(ctx, encryptorKey{}, encryptor)
+}
+
+func EncryptorFromContext(ctx context.Context) *Encryptor {
+	if ctx != nil {
+		if encryptor, ok := ctx.Value(encryptorKey{}).(*Encryptor); ok && encryptor != nil {
+			return encryptor
+		}
+	}
+	return DefaultEncryptor
+}
+
+// withDefaultEncryptor sets the encryptor of a wrapper in the context, unless the context already has an encryptor.
+func withDefaultEncryptor(ctx context.Context, encryptor *Encryptor) context.Context {
+	if encryptor == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(encryptorKey{}).(*Encryptor); ok {
+		return ctx
+	}
+	return WithEncryptor(ctx, encryptor)
+}
+
+type encryptedField struct {
+	field         StructField
+	deterministic bool
+	blindIndex    bool
+}
+
+func getEncryptor(ctx context.Context) (*Encryptor, error) {
+	encryptor := EncryptorFromContext(ctx)
+	if encryptor == nil || encryptor.Keys == nil {
+		return nil, errors.New("no encryptor for the encrypted fields")
+	}
+	return encryptor, nil
+}
+
+func (e *Encryptor) Encrypt(ctx context.Context, plaintext string, deterministic bool) (string, error) {
+	id, err := e.Keys.CurrentKeyId(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, err := e.Keys.Key(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return encryptWithKey(id, key, plaintext, deterministic)
+}
+
+// Decrypt returns the value as is if it is not encrypted, such as a value written before the field was encrypted.
+func (e *Encryptor) Decrypt(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	parts := strings.SplitN(value[len(encryptedPrefix):], ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("invalid encrypted value")
+	}
+	key, err := e.Keys.Key(ctx, parts[0])
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (e *Encryptor) BlindIndex(value string) (string, error) {
+	if len(e.IndexKey) == 0 {
+		return "", errors.New("no index key for the blind index")
+	}
+	mac := hmac.New(sha256.New, e.IndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptWithKey(id string, key []byte, plaintext string, deterministic bool) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if deterministic {
+		derived := hmac.New(sha256.New, key)
+		derived.Write([]byte("nonce"))
+		mac := hmac.New(sha256.New, derived.Sum(nil))
+		mac.Write([]byte(plaintext))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + id + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// EncryptBody encrypts the encrypted fields of the document of the model type, by their json names, and adds their blind indexes.
+func EncryptBody(ctx context.Context, modelType reflect.Type, body map[string]interface{}) error {
+	meta := GetMetadata(modelType)
+	if len(meta.encrypted) == 0 || body == nil {
+		return nil
+	}
+	encryptor, err := getEncryptor(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range meta.encrypted {
+		value, ok := body[f.field.JsonName]
+		if !ok {
+			continue
+		}
+		s, ok, err := stringValue(value)
+		if err != nil {
+			return fmt.Errorf("encrypted field '%s': %s", f.field.JsonName, err.Error())
+		}
+		if !ok {
+			if f.blindIndex {
+				body[f.field.JsonName+BlindIndexSuffix] = nil
+			}
+			continue
+		}
+		if body[f.field.JsonName], err = encryptor.Encrypt(ctx, s, f.deterministic); err != nil {
+			return err
+		}
+		if f.blindIndex {
+			if body[f.field.JsonName+BlindIndexSuffix], err = encryptor.BlindIndex(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EncryptPatch encrypts the values of the operations "add", "replace" and "test" on the encrypted fields of the model type, and keeps their blind indexes up to date.
+// A "test" on a field with a blind index tests its blind index, and on a deterministic field the ciphertext of the current key; a field encrypted with a random nonce cannot be tested, and an encrypted field cannot be moved.
+func EncryptPatch(ctx context.Context, modelType reflect.Type, operations []PatchOperation) ([]PatchOperation, error) {
+	meta := GetMetadata(modelType)
+	if len(meta.encrypted) == 0 {
+		return operations, nil
+	}
+	fields := make(map[string]encryptedField, len(meta.encrypted))
+	for _, f := range meta.encrypted {
+		fields["/"+f.field.JsonName] = f
+	}
+	encryptor, err := getEncryptor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PatchOperation, 0, len(operations))
+	for _, op := range operations {
+		f, ok := fields[op.Path]
+		if op.Op == "move" {
+			if _, from := fields[op.From]; ok || from {
+				return nil, fmt.Errorf("cannot move the encrypted field '%s'", op.From)
+			}
+		}
+		if !ok || op.Op == "move" {
+			result = append(result, op)
+			continue
+		}
+		bidx := op.Path + BlindIndexSuffix
+		s, hasValue, err := stringValue(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted field '%s': %s", f.field.JsonName, err.Error())
+		}
+		switch {
+		case op.Op == "remove":
+			result = append(result, op)
+			if f.blindIndex {
+				result = append(result, PatchOperation{Op: "add", Path: bidx})
+			}
+		case op.Op == "test" && f.blindIndex:
+			op.Path = bidx
+			if hasValue {
+				if op.Value, err = encryptor.BlindIndex(s); err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, op)
+		case op.Op == "test" && !f.deterministic:
+			return nil, fmt.Errorf("the encrypted field '%s' is neither deterministic nor with a blind index, and cannot be tested", f.field.JsonName)
+		default:
+			var index interface{}
+			if hasValue {
+				if op.Value, err = encryptor.Encrypt(ctx, s, f.deterministic); err != nil {
+					return nil, err
+				}
+				if f.blindIndex {
+					if index, err = encryptor.BlindIndex(s); err != nil {
+						return nil, err
+					}
+				}
+			}
+			result = append(result, op)
+			if f.blindIndex && op.Op != "test" {
+				result = append(result, PatchOperation{Op: "add", Path: bidx, Value: index})
+			}
+		}
+	}
+	return result, nil
+}
+
+// stringValue returns false for a null value.
+func stringValue(value interface{}) (string, bool, error) {
+	if value == nil {
+		return "", false, nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.String {
+		return "", false, errors.New("only a string can be encrypted")
+	}
+	return v.String(), true, nil
+}
+
+type encryptedTypeKey struct{}
+
+// withEncryptedType makes DecryptModel also decrypt the fields of a result, such as a projection, which are encrypted in the model type, by their json names.
+func withEncryptedType(ctx context.Context, modelType reflect.Type) context.Context {
+	return context.WithValue(ctx, encryptedTypeKey{}, modelType)
+}
+
+// decryptedFields returns the encrypted fields of the result type and the fields of the result type with the json names of the encrypted fields of the model type of the context.
+func decryptedFields(ctx context.Context, resultType reflect.Type) []StructField {
+	meta := GetMetadata(resultType)
+	fields := make([]StructField, 0, len(meta.encrypted))
+	names := make(map[string]bool, len(meta.encrypted))
+	for _, f := range meta.encrypted {
+		fields = append(fields, f.field)
+		names[f.field.JsonName] = true
+	}
+	if ctx == nil {
+		return fields
+	}
+	modelType, _ := ctx.Value(encryptedTypeKey{}).(reflect.Type)
+	if modelType == nil {
+		return fields
+	}
+	result := getStructFields(resultType)
+	for _, f := range GetMetadata(modelType).encrypted {
+		if i, ok := result.byJson[f.field.JsonName]; ok && !names[f.field.JsonName] {
+			fields = append(fields, result.list[i])
+			names[f.field.JsonName] = true
+		}
+	}
+	return fields
+}
+
+// DecryptModel decrypts the encrypted fields of the model, a pointer to a struct.
+// If the model is a projection of a model with encrypted fields, given by the loader or the search builder, the fields with their json names are decrypted too.
+func DecryptModel(ctx context.Context, model interface{}) error {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	fields := decryptedFields(ctx, v.Type())
+	if len(fields) == 0 {
+		return nil
+	}
+	encryptor, err := getEncryptor(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		field, ok := FieldByIndex(v, f.Index)
+		if !ok || !field.CanSet() {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		if field.Kind() != reflect.String || len(field.String()) == 0 {
+			continue
+		}
+		s, err := encryptor.Decrypt(ctx, field.String())
+		if err != nil {
+			return fmt.Errorf("encrypted field '%s': %s", f.JsonName, err.Error())
+		}
+		field.SetString(s)
+	}
+	return nil
+}
+
+// decryptModels decrypts the models of result, a pointer to a slice.
+func decryptModels(ctx context.Context, result interface{}) error {
+	slice := reflect.Indirect(reflect.ValueOf(result))
+	if slice.Kind() != reflect.Slice || len(decryptedFields(ctx, slice.Type().Elem())) == 0 {
+		return nil
+	}
+	for i := 0; i < slice.Len(); i++ {
+		item := slice.Index(i)
+		if item.Kind() != reflect.Ptr {
+			item = item.Addr()
+		}
+		if err := DecryptModel(ctx, item.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncryptQuery translates the equality clauses (term, terms, match and match_phrase) on the encrypted fields of the model type: a deterministic field is matched by its ciphertexts by all keys, and a field with a blind index by the hashes in its blind index field.
+// The query is a search body or a query clause; the clauses of bool, constant_score, dis_max and boosting are translated too.
+// A field encrypted with a random nonce cannot be matched, and any other clause on an encrypted field, such as a range, or a clause on an encrypted field in a nested, has_child or has_parent clause, returns an error.
+func EncryptQuery(ctx context.Context, modelType reflect.Type, query map[string]interface{}) (map[string]interface{}, error) {
+	meta := GetMetadata(modelType)
+	if len(meta.encrypted) == 0 || query == nil {
+		return query, nil
+	}
+	encryptor, err := getEncryptor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]encryptedField, len(meta.encrypted))
+	for _, f := range meta.encrypted {
+		fields[f.field.JsonName] = f
+	}
+	if _, ok := query["query"]; !ok {
+		r, err := encryptor.encryptClause(ctx, fields, query)
+		if err != nil {
+			return nil, err
+		}
+		return r.(map[string]interface{}), nil
+	}
+	body := make(map[string]interface{}, len(query))
+	for k, v := range query {
+		body[k] = v
+	}
+	for _, k := range []string{"query", "post_filter"} {
+		if clause, ok := query[k]; ok {
+			r, err := encryptor.encryptClause(ctx, fields, clause)
+			if err != nil {
+				return nil, err
+			}
+			body[k] = r
+		}
+	}
+	return body, nil
+}
+
+func (e *Encryptor) encryptClause(ctx context.Context, fields map[string]encryptedField, clause interface{}) (interface{}, error) {
+	c, ok := clause.(map[string]interface{})
+	if !ok {
+		return clause, nil
+	}
+	result := make(map[string]interface{}, len(c))
+	for op, inner := range c {
+		var r interface{}
+		var err error
+		switch op {
+		case "term", "terms", "match", "match_phrase":
+			m, ok := inner.(map[string]interface{})
+			if !ok {
+				r = inner
+				break
+			}
+			for name, value := range m {
+				if f, ok := fields[name]; ok {
+					if len(m) != 1 {
+						return nil, fmt.Errorf("encrypted field '%s': a %s clause with other parameters is not supported", name, op)
+					}
+					return e.equality(ctx, f, value)
+				}
+			}
+			r = inner
+		case "bool":
+			r, err = e.encryptClauses(ctx, fields, inner, "must", "filter", "should", "must_not")
+		case "constant_score":
+			r, err = e.encryptClauses(ctx, fields, inner, "filter")
+		case "dis_max":
+			r, err = e.encryptClauses(ctx, fields, inner, "queries")
+		case "boosting":
+			r, err = e.encryptClauses(ctx, fields, inner, "positive", "negative")
+		case "exists":
+			r = inner
+		default:
+			if name, ok := findEncryptedField(fields, inner); ok {
+				return nil, fmt.Errorf("encrypted field '%s': a %s clause is not supported", name, op)
+			}
+			r = inner
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[op] = r
+	}
+	return result, nil
+}
+
+// encryptClauses translates the clauses of a compound clause by their keys, each a clause or an array of clauses.
+func (e *Encryptor) encryptClauses(ctx context.Context, fields map[string]encryptedField, compound interface{}, keys ...string) (interface{}, error) {
+	m, ok := compound.(map[string]interface{})
+	if !ok {
+		return compound, nil
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			r, err := e.encryptClause(ctx, fields, v)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = r
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			for i, item := range v {
+				r, err := e.encryptClause(ctx, fields, item)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = r
+			}
+			result[k] = items
+		}
+	}
+	return result, nil
+}
+
+// findEncryptedField returns the first encrypted field referenced in a clause, as a key or as the value of a field, fields or default_field parameter.
+func findEncryptedField(fields map[string]encryptedField, clause interface{}) (string, bool) {
+	switch c := clause.(type) {
+	case map[string]interface{}:
+		for k, v := range c {
+			if _, ok := fields[k]; ok {
+				return k, true
+			}
+			if k == "field" || k == "fields" || k == "default_field" {
+				if name, ok := findEncryptedName(fields, v); ok {
+					return name, true
+				}
+			}
+			if name, ok := findEncryptedField(fields, v); ok {
+				return name, true
+			}
+		}
+	case []interface{}:
+		for _, v := range c {
+			if name, ok := findEncryptedField(fields, v); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func findEncryptedName(fields map[string]encryptedField, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		name := strings.Split(v, "^")[0]
+		if _, ok := fields[name]; ok {
+			return name, true
+		}
+	case []string:
+		for _, s := range v {
+			if name, ok := findEncryptedName(fields, s); ok {
+				return name, true
+			}
+		}
+	case []interface{}:
+		for _, s := range v {
+			if name, ok := findEncryptedName(fields, s); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (e *Encryptor) equality(ctx context.Context, f encryptedField, value interface{}) (interface{}, error) {
+	var values []string
+	if m, ok := value.(map[string]interface{}); ok {
+		value = m["query"]
+		if value == nil {
+			value = m["value"]
+		}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, fmt.Sprint(v.Index(i).Interface()))
+		}
+	} else if value != nil && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		values = append(values, fmt.Sprint(reflect.Indirect(v).Interface()))
+	}
+	var terms []string
+	var name string
+	if f.blindIndex {
+		name = f.field.JsonName + BlindIndexSuffix
+		for _, s := range values {
+			hash, err := e.BlindIndex(s)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, hash)
+		}
+	} else if f.deterministic {
+		name = f.field.JsonName
+		ids, err := e.Keys.KeyIds(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			key, err := e.Keys.Key(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range values {
+				encrypted, err := encryptWithKey(id, key, s, true)
+				if err != nil {
+					return nil, err
+				}
+				terms = append(terms, encrypted)
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("the encrypted field '%s' is neither deterministic nor with a blind index, and cannot be searched", f.field.JsonName)
+	}
+	return map[string]interface{}{"terms": map[string]interface{}{name: terms}}, nil
+}
@@ -0,0 +1,277 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type encryptedUser struct {
+	Id         string  `json:"id" bson:"_id"`
+	Name       string  `json:"name"`
+	Note       string  `json:"note" es:"keyword,encrypted"`
+	Email      string  `json:"email" es:"keyword,encrypted,deterministic"`
+	NationalId *string `json:"nationalId" es:"keyword,encrypted,blindIndex"`
+}
+
+var (
+	oldKey   = []byte("0123456789abcdef0123456789abcdef")
+	newKey   = []byte("fedcba9876543210fedcba9876543210")
+	indexKey = []byte("index key")
+)
+
+func newTestEncryptor(current string) *Encryptor {
+	return NewEncryptor(NewStaticKeyProvider(current, map[string][]byte{"k1": oldKey, "k2": newKey}), indexKey)
+}
+
+func encryptUser(t *testing.T, ctx context.Context, user encryptedUser) map[string]interface{} {
+	body := GetMetadata(reflect.TypeOf(user)).Body(user)
+	if err := EncryptBody(ctx, reflect.TypeOf(user), body); err != nil {
+		t.Fatalf("EncryptBody: %v", err)
+	}
+	return body
+}
+
+func decryptUser(t *testing.T, ctx context.Context, body map[string]interface{}) encryptedUser {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user encryptedUser
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptModel(ctx, &user); err != nil {
+		t.Fatalf("DecryptModel: %v", err)
+	}
+	return user
+}
+
+func TestEncryptBodyRoundTrip(t *testing.T) {
+	ctx := WithEncryptor(context.Background(), newTestEncryptor("k1"))
+	nationalId := "079123456789"
+	user := encryptedUser{Id: "1", Name: "Peter", Note: "secret", Email: "peter@example.com", NationalId: &nationalId}
+	body := encryptUser(t, ctx, user)
+	for _, name := range []string{"note", "email", "nationalId"} {
+		if s, _ := body[name].(string); !strings.HasPrefix(s, "enc:k1:") {
+			t.Errorf("%s is not encrypted by the current key: %v", name, body[name])
+		}
+	}
+	if body["name"] != "Peter" {
+		t.Errorf("name should not be encrypted: %v", body["name"])
+	}
+	if _, ok := body["nationalId"+BlindIndexSuffix].(string); !ok {
+		t.Errorf("missing blind index of nationalId")
+	}
+	user.Id = ""
+	if got := decryptUser(t, ctx, body); !reflect.DeepEqual(got, user) {
+		t.Errorf("decrypted %+v, expected %+v", got, user)
+	}
+}
+
+func TestEncryptNil(t *testing.T) {
+	ctx := WithEncryptor(context.Background(), newTestEncryptor("k1"))
+	body := encryptUser(t, ctx, encryptedUser{Id: "1"})
+	if body["nationalId"] != (*string)(nil) {
+		t.Errorf("a nil field should not be encrypted: %v", body["nationalId"])
+	}
+	if v, ok := body["nationalId"+BlindIndexSuffix]; !ok || v != nil {
+		t.Errorf("the blind index of a nil field should be null: %v", v)
+	}
+}
+
+func TestEncryptDeterministic(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEncryptor("k1")
+	a, err := e.Encrypt(ctx, "peter@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := e.Encrypt(ctx, "peter@example.com", true)
+	if a != b {
+		t.Errorf("a deterministic value should have a single ciphertext: %s, %s", a, b)
+	}
+	c, _ := e.Encrypt(ctx, "secret", false)
+	d, _ := e.Encrypt(ctx, "secret", false)
+	if c == d {
+		t.Errorf("a random value should have different ciphertexts: %s", c)
+	}
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	before := WithEncryptor(ctx, NewEncryptor(NewStaticKeyProvider("k1", map[string][]byte{"k1": oldKey}), indexKey))
+	after := WithEncryptor(ctx, newTestEncryptor("k2"))
+	nationalId := "079123456789"
+	user := encryptedUser{Note: "secret", Email: "peter@example.com", NationalId: &nationalId}
+	old := encryptUser(t, before, user)
+
+	if got := decryptUser(t, after, old); !reflect.DeepEqual(got, user) {
+		t.Errorf("the old key should decrypt the values encrypted before the rotation: %+v", got)
+	}
+	current := encryptUser(t, after, user)
+	for _, name := range []string{"note", "email", "nationalId"} {
+		if s, _ := current[name].(string); !strings.HasPrefix(s, "enc:k2:") {
+			t.Errorf("%s is not encrypted by the current key: %v", name, current[name])
+		}
+	}
+	if old["nationalId"+BlindIndexSuffix] != current["nationalId"+BlindIndexSuffix] {
+		t.Errorf("the blind index should not change with the rotation")
+	}
+	if err := DecryptModel(before, &encryptedUser{Note: current["note"].(string)}); err == nil {
+		t.Errorf("a value of an unknown key should not be decrypted")
+	}
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	user := encryptedUser{Note: "written before the encryption"}
+	if err := DecryptModel(WithEncryptor(context.Background(), newTestEncryptor("k1")), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Note != "written before the encryption" {
+		t.Errorf("a plaintext value should be kept: %s", user.Note)
+	}
+}
+
+func TestEncryptQuery(t *testing.T) {
+	ctx := WithEncryptor(context.Background(), newTestEncryptor("k2"))
+	e := newTestEncryptor("k2")
+	query := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				map[string]interface{}{"term": map[string]interface{}{"email": "peter@example.com"}},
+				map[string]interface{}{"match": map[string]interface{}{"nationalId": map[string]interface{}{"query": "079123456789"}}},
+				map[string]interface{}{"term": map[string]interface{}{"name": "Peter"}},
+			},
+		},
+	}
+	r, err := EncryptQuery(ctx, reflect.TypeOf(encryptedUser{}), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := r["bool"].(map[string]interface{})["filter"].([]interface{})
+
+	emails := filter[0].(map[string]interface{})["terms"].(map[string]interface{})["email"].([]string)
+	k1, _ := encryptWithKey("k1", oldKey, "peter@example.com", true)
+	k2, _ := encryptWithKey("k2", newKey, "peter@example.com", true)
+	expected := []string{k1, k2}
+	sort.Strings(emails)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(emails, expected) {
+		t.Errorf("a deterministic field should match its ciphertexts by all keys: %v", emails)
+	}
+
+	hash, _ := e.BlindIndex("079123456789")
+	nationalIds := filter[1].(map[string]interface{})["terms"].(map[string]interface{})["nationalId"+BlindIndexSuffix]
+	if !reflect.DeepEqual(nationalIds, []string{hash}) {
+		t.Errorf("a field with a blind index should match its blind index: %v", filter[1])
+	}
+	if !reflect.DeepEqual(filter[2], query["bool"].(map[string]interface{})["filter"].([]interface{})[2]) {
+		t.Errorf("a clause on a plain field should be kept: %v", filter[2])
+	}
+
+	random := map[string]interface{}{"term": map[string]interface{}{"note": "secret"}}
+	if _, err := EncryptQuery(ctx, reflect.TypeOf(encryptedUser{}), random); err == nil {
+		t.Errorf("a field encrypted with a random nonce should not be searched")
+	}
+}
+
+func TestEncryptQueryClauses(t *testing.T) {
+	ctx := WithEncryptor(context.Background(), newTestEncryptor("k1"))
+	modelType := reflect.TypeOf(encryptedUser{})
+	email := map[string]interface{}{"term": map[string]interface{}{"email": "peter@example.com"}}
+	body := map[string]interface{}{
+		"query": map[string]interface{}{"constant_score": map[string]interface{}{"filter": map[string]interface{}{
+			"bool": map[string]interface{}{"should": []interface{}{email}},
+		}}},
+		"_source": true,
+	}
+	r, err := EncryptQuery(ctx, modelType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	should := r["query"].(map[string]interface{})["constant_score"].(map[string]interface{})["filter"].(map[string]interface{})["bool"].(map[string]interface{})["should"].([]interface{})
+	if _, ok := should[0].(map[string]interface{})["terms"]; !ok {
+		t.Errorf("a clause of a search body should be translated: %v", should[0])
+	}
+	if r["_source"] != true {
+		t.Errorf("the other parameters of a search body should be kept: %v", r)
+	}
+
+	unsupported := map[string]map[string]interface{}{
+		"range":  {"range": map[string]interface{}{"email": map[string]interface{}{"gte": "a"}}},
+		"nested": {"nested": map[string]interface{}{"path": "contacts", "query": map[string]interface{}{"bool": map[string]interface{}{"must": []interface{}{email}}}}},
+		"has_child": {"bool": map[string]interface{}{"filter": []interface{}{
+			map[string]interface{}{"has_child": map[string]interface{}{"type": "order", "query": email}},
+		}}},
+		"multi_match": {"multi_match": map[string]interface{}{"query": "peter", "fields": []interface{}{"name", "email^2"}}},
+	}
+	for name, query := range unsupported {
+		if _, err := EncryptQuery(ctx, modelType, query); err == nil {
+			t.Errorf("%s: a clause on an encrypted field which cannot be translated should return an error", name)
+		}
+	}
+	plain := map[string]interface{}{"nested": map[string]interface{}{"path": "contacts", "query": map[string]interface{}{"term": map[string]interface{}{"contacts.phone": "1"}}}}
+	if r, err := EncryptQuery(ctx, modelType, plain); err != nil || !reflect.DeepEqual(r, plain) {
+		t.Errorf("a clause without encrypted fields should be kept: %v, %v", r, err)
+	}
+}
+
+type userName struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func TestDecryptProjection(t *testing.T) {
+	ctx := WithEncryptor(context.Background(), newTestEncryptor("k1"))
+	body := encryptUser(t, ctx, encryptedUser{Id: "1", Name: "Peter", Email: "peter@example.com"})
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result userName
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptModel(withEncryptedType(ctx, reflect.TypeOf(encryptedUser{})), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Email != "peter@example.com" || result.Name != "Peter" {
+		t.Errorf("a projection should be decrypted by the encrypted fields of the model: %+v", result)
+	}
+}
+
+func TestEncryptPatch(t *testing.T) {
+	ctx := WithEncryptor(context.Background(), newTestEncryptor("k1"))
+	operations := []PatchOperation{
+		{Op: "replace", Path: "/nationalId", Value: "079123456789"},
+		{Op: "remove", Path: "/nationalId"},
+		{Op: "replace", Path: "/name", Value: "Peter"},
+	}
+	r, err := EncryptPatch(ctx, reflect.TypeOf(encryptedUser{}), operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, len(r))
+	for i, op := range r {
+		paths[i] = op.Op + " " + op.Path
+	}
+	expected := []string{"replace /nationalId", "add /nationalId_bidx", "remove /nationalId", "add /nationalId_bidx", "replace /name"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("operations %v, expected %v", paths, expected)
+	}
+	if s, _ := r[0].Value.(string); !strings.HasPrefix(s, "enc:k1:") {
+		t.Errorf("the value is not encrypted: %v", r[0].Value)
+	}
+	if r[3].Value != nil {
+		t.Errorf("the blind index of a removed field should be null: %v", r[3].Value)
+	}
+
+	test := []PatchOperation{{Op: "test", Path: "/note", Value: "secret"}}
+	if _, err := EncryptPatch(ctx, reflect.TypeOf(encryptedUser{}), test); err == nil {
+		t.Errorf("a field encrypted with a random nonce should not be tested")
+	}
+}
@@ -2,11 +2,8 @@ package elasticsearch
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
-	"github.com/elastic/go-elasticsearch/v7/esapi"
-	"github.com/elastic/go-elasticsearch/v7/esutil"
 )
 
 type FieldLoader struct {
@@ -23,40 +20,21 @@ func NewFieldLoader(db *elasticsearch.Client, indexName, name string) *FieldLoad
 	}
 }
 
+// Values returns the values of the field of the documents, in the order of the ids. The ids which are not found are skipped.
 func (l *FieldLoader) Values(ctx context.Context, ids []string) ([]string, error) {
 	var array []string
-	query := make(map[string]interface{})
-	query["terms"] = map[string]interface{}{"_id": ids}
-	query["boost"] = 1.0
-	req := esapi.SearchRequest{
-		Index:          []string{l.indexName},
-		Body:           esutil.NewJSONReader(query),
-		TrackTotalHits: true,
-		Pretty:         true,
-	}
-	res, err := req.Do(ctx, l.client)
-	if err != nil {
-		return array, err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return array, fmt.Errorf("response error")
-	}
-
-	var temp map[string]interface{}
-	err = json.NewDecoder(res.Body).Decode(&temp)
-	if err != nil {
-		return array, err
-	}
-
-	hits := temp["hits"].(map[string]interface{})["hits"].([]interface{})
-	result := make([]map[string]interface{}, 0)
-	if err := json.NewDecoder(esutil.NewJSONReader(hits)).Decode(&result); err != nil {
+	var result []map[string]interface{}
+	if _, err := FindByIdsAndDecode(ctx, l.client, l.indexName, ids, &result, []string{l.name}); err != nil {
 		return array, err
 	}
 	for idx := range result {
-		array = append(array, result[idx][l.name].(string))
+		switch v := result[idx][l.name].(type) {
+		case nil:
+		case string:
+			array = append(array, v)
+		default:
+			array = append(array, fmt.Sprintf("%v", v))
+		}
 	}
 	return array, nil
 }
@@ -0,0 +1,220 @@
+package elasticsearch
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StructField is a field of a model as encoding/json sees it: the fields of embedded structs are promoted, and Index is the index sequence for reflect.Value.FieldByIndex.
+type StructField struct {
+	Index     []int
+	Name      string
+	JsonName  string
+	OmitEmpty bool
+	Type      reflect.Type
+	Tag       reflect.StructTag
+}
+
+type structFields struct {
+	list   []StructField
+	byName map[string]int
+	byJson map[string]int
+}
+
+var fieldCache sync.Map
+
+// GetStructFields returns the fields of the model encoded by encoding/json, in the order of encoding. The result is cached by type.
+func GetStructFields(modelType reflect.Type) []StructField {
+	return getStructFields(modelType).list
+}
+
+func getStructFields(modelType reflect.Type) *structFields {
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if f, ok := fieldCache.Load(modelType); ok {
+		return f.(*structFields)
+	}
+	fields := &structFields{byName: make(map[string]int), byJson: make(map[string]int)}
+	if modelType.Kind() == reflect.Struct {
+		fields.list = typeFields(modelType)
+	}
+	for i, field := range fields.list {
+		fields.byName[field.Name] = i
+		fields.byJson[field.JsonName] = i
+	}
+	f, _ := fieldCache.LoadOrStore(modelType, fields)
+	return f.(*structFields)
+}
+
+// FindStructField returns the field of the json path, such as "address.city" for the field City of the nested struct Address; the index sequence goes through the nested structs.
+func FindStructField(modelType reflect.Type, path string) (StructField, bool) {
+	var index []int
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fields := getStructFields(modelType)
+		j, ok := fields.byJson[name]
+		if !ok {
+			return StructField{}, false
+		}
+		field := fields.list[j]
+		index = append(index, field.Index...)
+		if i == len(names)-1 {
+			field.Index = index
+			field.JsonName = path
+			return field, true
+		}
+		modelType = field.Type
+		for modelType.Kind() == reflect.Ptr {
+			modelType = modelType.Elem()
+		}
+		if modelType.Kind() != reflect.Struct {
+			return StructField{}, false
+		}
+	}
+	return StructField{}, false
+}
+
+// FieldByIndex returns the field of the index sequence, or false if a pointer to an embedded or nested struct is nil.
+func FieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	v = reflect.Indirect(v)
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		if v.Kind() != reflect.Struct || x >= v.NumField() {
+			return reflect.Value{}, false
+		}
+		v = v.Field(x)
+	}
+	return v, len(index) > 0
+}
+
+// fieldByIndexAlloc returns the field of the index sequence, allocating the nil pointers to embedded or nested structs, to set the field.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	v = reflect.Indirect(v)
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// getString returns the string value of the field of the index sequence, or "" if a pointer to its struct is nil.
+func getString(model interface{}, index []int) string {
+	if v, ok := FieldByIndex(reflect.ValueOf(model), index); ok {
+		return v.String()
+	}
+	return ""
+}
+
+// topIndex returns the index of the top level field holding the field: the field itself, the embedded struct of a promoted field, or the struct of a nested path.
+func topIndex(field StructField) int {
+	if len(field.Index) > 0 {
+		return field.Index[0]
+	}
+	return -1
+}
+
+// typeFields follows the rules of encoding/json: a field at a lower depth hides the fields of the same name at higher depths, and among the fields of the same depth, the tagged field wins; the other conflicting fields are ignored.
+func typeFields(t reflect.Type) []StructField {
+	type entry struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []StructField
+	found := make(map[string]bool)
+	visited := make(map[reflect.Type]bool)
+	next := []entry{{typ: t}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		var level []StructField
+		tagged := make(map[string]int)
+		count := make(map[string]int)
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				options := strings.Split(tag, ",")
+				name := options[0]
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+				if len(name) == 0 && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, entry{typ: ft, index: index})
+					continue
+				}
+				field := StructField{Index: index, Name: sf.Name, JsonName: name, Type: sf.Type, Tag: sf.Tag}
+				if len(name) == 0 {
+					field.JsonName = sf.Name
+				} else {
+					tagged[name]++
+				}
+				for _, o := range options[1:] {
+					if o == "omitempty" {
+						field.OmitEmpty = true
+					}
+				}
+				count[field.JsonName]++
+				level = append(level, field)
+			}
+		}
+		for _, field := range level {
+			name := field.JsonName
+			if found[name] {
+				continue
+			}
+			if count[name] > 1 && (tagged[name] != 1 || len(strings.Split(field.Tag.Get("json"), ",")[0]) == 0) {
+				continue
+			}
+			fields = append(fields, field)
+		}
+		for _, field := range level {
+			found[field.JsonName] = true
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
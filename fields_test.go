@@ -0,0 +1,138 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type Entity struct {
+	Id        string `json:"id" bson:"_id"`
+	CreatedBy string `json:"createdBy,omitempty"`
+}
+
+type Tracking struct {
+	UpdatedBy string `json:"updatedBy"`
+	Note      string `json:"note"`
+}
+
+type address struct {
+	City string `json:"city"`
+}
+
+type Left struct {
+	Name string
+	Code string `json:"code"`
+}
+
+type Right struct {
+	Name string
+	Code string
+}
+
+type embeddedModel struct {
+	Entity
+	*Tracking
+	address
+	Note     string `json:"note"`
+	Secret   string `json:"-"`
+	Dash     string `json:"-,"`
+	Count    int    `json:"count,omitempty"`
+	Tags     []string
+	internal string
+}
+
+type conflictModel struct {
+	Left
+	Right
+}
+
+type taggedEmbeddedModel struct {
+	Entity `json:"base"`
+	Title  string `json:"title,omitempty"`
+}
+
+// encodeFields encodes the fields of GetStructFields as encoding/json would encode the model.
+func encodeFields(model interface{}) map[string]interface{} {
+	value := reflect.ValueOf(model)
+	result := make(map[string]interface{})
+	for _, field := range GetStructFields(value.Type()) {
+		v, ok := FieldByIndex(value, field.Index)
+		if !ok || (field.OmitEmpty && isEmptyValue(v)) {
+			continue
+		}
+		result[field.JsonName] = v.Interface()
+	}
+	return result
+}
+
+// isEmptyValue reports whether the value is omitted by encoding/json for a field with the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func decodeJson(t *testing.T, v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestStructFieldsAsEncodingJson(t *testing.T) {
+	models := map[string]interface{}{
+		"embedded":              embeddedModel{Entity: Entity{Id: "1", CreatedBy: "peter"}, Tracking: &Tracking{UpdatedBy: "mary", Note: "hidden"}, address: address{City: "Hanoi"}, Note: "visible", Secret: "s", Dash: "d", Count: 2, Tags: []string{"a"}, internal: "i"},
+		"nil embedded pointer":  embeddedModel{Entity: Entity{Id: "1"}, Note: "visible"},
+		"omitempty":             embeddedModel{},
+		"conflicting names":     conflictModel{Left: Left{Name: "l", Code: "lc"}, Right: Right{Name: "r", Code: "rc"}},
+		"tagged embedded field": taggedEmbeddedModel{Entity: Entity{Id: "1"}, Title: "t"},
+	}
+	for name, model := range models {
+		expected := decodeJson(t, model)
+		if got := decodeJson(t, encodeFields(model)); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: encoded %v, expected %v", name, got, expected)
+		}
+	}
+}
+
+func TestFindPromotedField(t *testing.T) {
+	modelType := reflect.TypeOf(embeddedModel{})
+	if index, jsonName := FindFieldByName(modelType, "UpdatedBy"); index != 1 || jsonName != "updatedBy" {
+		t.Errorf("FindFieldByName of a promoted field: %d, %s", index, jsonName)
+	}
+	if index, name := FindFieldByJson(modelType, "city"); index != 2 || name != "City" {
+		t.Errorf("FindFieldByJson of a promoted field: %d, %s", index, name)
+	}
+	if index, name, jsonName := FindIdField(modelType); index != 0 || name != "Id" || jsonName != "id" {
+		t.Errorf("FindIdField of a promoted field: %d, %s, %s", index, name, jsonName)
+	}
+	if index, _ := FindFieldByJson(modelType, "note"); index != 3 {
+		t.Errorf("the field note should hide the promoted field note: %d", index)
+	}
+	for _, name := range []string{"Secret", "internal"} {
+		if index, _ := FindFieldByName(modelType, name); index >= 0 {
+			t.Errorf("%s should not be found: %d", name, index)
+		}
+	}
+	if index, _ := FindFieldByName(reflect.TypeOf(conflictModel{}), "Name"); index >= 0 {
+		t.Errorf("the conflicting field Name should not be found: %d", index)
+	}
+}
@@ -0,0 +1,76 @@
+package elasticsearch
+
+import (
+	"errors"
+	"strings"
+)
+
+const geoDistanceSort = "_geo_distance"
+
+// BuildSortBody converts the sort of BuildSortOrders to the "sort" of a search body. "_geo_distance" sorts by the distance from the point of the geo_distance filter of the query, as built by query.BuildBody;
+// it returns an error if the query has no geo_distance filter.
+func BuildSortBody(query map[string]interface{}, sort []string) ([]interface{}, error) {
+	var body []interface{}
+	for _, s := range sort {
+		kv := strings.SplitN(s, ":", 2)
+		if len(kv) != 2 {
+			body = append(body, s)
+			continue
+		}
+		if kv[0] == geoDistanceSort {
+			field, point, unit, ok := findGeoDistance(query)
+			if !ok {
+				return nil, errors.New("the sort by distance requires a geo distance filter in the query")
+			}
+			body = append(body, map[string]interface{}{
+				geoDistanceSort: map[string]interface{}{field: point, "order": kv[1], "unit": unit},
+			})
+			continue
+		}
+		body = append(body, map[string]interface{}{kv[0]: kv[1]})
+	}
+	return body, nil
+}
+
+func hasGeoSort(sort []string) bool {
+	for _, s := range sort {
+		if strings.HasPrefix(s, geoDistanceSort) {
+			return true
+		}
+	}
+	return false
+}
+
+// findGeoDistance returns the field and the point of the first geo_distance filter of the query, and the unit of its distance script field.
+func findGeoDistance(query map[string]interface{}) (string, interface{}, string, bool) {
+	q, _ := query["query"].(map[string]interface{})
+	b, _ := q["bool"].(map[string]interface{})
+	filter, _ := b["filter"].([]interface{})
+	for _, f := range filter {
+		m, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		geo, ok := m["geo_distance"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for field, point := range geo {
+			if field != "distance" && field != "distance_type" && field != "validation_method" {
+				return field, point, distanceUnit(query), true
+			}
+		}
+	}
+	return "", nil, "", false
+}
+
+func distanceUnit(query map[string]interface{}) string {
+	fields, _ := query["script_fields"].(map[string]interface{})
+	distance, _ := fields["_distance"].(map[string]interface{})
+	script, _ := distance["script"].(map[string]interface{})
+	params, _ := script["params"].(map[string]interface{})
+	if unit, ok := params["unit"].(string); ok && len(unit) > 0 {
+		return unit
+	}
+	return "km"
+}
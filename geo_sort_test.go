@@ -0,0 +1,51 @@
+package elasticsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildSort(t *testing.T) {
+	tests := []struct {
+		sort   string
+		names  []string
+		orders []string
+	}{
+		{"", nil, nil},
+		{"name", []string{"name"}, []string{"name:asc"}},
+		{"-createdAt, +name", []string{"createdAt", "name"}, []string{"createdAt:desc", "name:asc"}},
+		{"_distance,-age", []string{"_distance", "age"}, []string{"_geo_distance:asc", "age:desc"}},
+	}
+	for _, tt := range tests {
+		if names := BuildSort(tt.sort); !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("BuildSort(%q) = %v, expected %v", tt.sort, names, tt.names)
+		}
+		if orders := BuildSortOrders(tt.sort); !reflect.DeepEqual(orders, tt.orders) {
+			t.Errorf("BuildSortOrders(%q) = %v, expected %v", tt.sort, orders, tt.orders)
+		}
+	}
+}
+
+func TestBuildSortBody(t *testing.T) {
+	point := map[string]interface{}{"lat": 10.5, "lon": 106.7}
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{
+			map[string]interface{}{"geo_distance": map[string]interface{}{"distance": "5km", "location": point}},
+		}}},
+		"script_fields": map[string]interface{}{"_distance": map[string]interface{}{"script": map[string]interface{}{"params": map[string]interface{}{"unit": "m"}}}},
+	}
+	body, err := BuildSortBody(query, BuildSortOrders("_distance,-name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"_geo_distance": map[string]interface{}{"location": point, "order": "asc", "unit": "m"}},
+		map[string]interface{}{"name": "desc"},
+	}
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("sort %v, expected %v", body, expected)
+	}
+	if _, err := BuildSortBody(map[string]interface{}{}, BuildSortOrders("-_distance")); err == nil {
+		t.Error("expected an error for a sort by distance without a geo distance filter")
+	}
+}
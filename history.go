@@ -0,0 +1,373 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	OperationInsert  = "insert"
+	OperationUpdate  = "update"
+	OperationPatch   = "patch"
+	OperationSave    = "save"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+	OperationScript  = "script"
+	OperationPurge   = "purge"
+)
+
+const purgePageSize = 1000
+
+// Change is an entry of the history of a document: the document before and after the write, and the top level fields which changed.
+// Before and After are stored but not indexed, since the documents of several models can share the history index.
+type Change struct {
+	Id        string                 `json:"id" es:"keyword"`
+	Index     string                 `json:"index" es:"keyword"`
+	Operation string                 `json:"operation" es:"keyword"`
+	Fields    []string               `json:"fields,omitempty" es:"keyword"`
+	Before    map[string]interface{} `json:"before,omitempty" es:"object,enabled:false"`
+	After     map[string]interface{} `json:"after,omitempty" es:"object,enabled:false"`
+	Actor     string                 `json:"actor,omitempty" es:"keyword"`
+	Time      time.Time              `json:"time" es:"date"`
+}
+
+// HistoryWriter is a Writer which records each write of a document as a Change in the history index.
+// If Diff is true, Before and After keep only the fields which changed. The actor is the user of the context, as for the audit fields, unless GetActor is set.
+// The change is recorded after the write: if the write succeeds but the change is not recorded, the write returns its count with a *HistoryError.
+type HistoryWriter struct {
+	*Writer
+	HistoryIndex string
+	Diff         bool
+	GetActor     func(ctx context.Context) string
+}
+
+// HistoryError is the error of a write which succeeded, but whose change could not be recorded in the history index.
+type HistoryError struct {
+	Operation string
+	Id        string
+	Err       error
+}
+
+func (e *HistoryError) Error() string {
+	return fmt.Sprintf("%s of %s succeeded but its history was not recorded: %s", e.Operation, e.Id, e.Err.Error())
+}
+
+func (e *HistoryError) Unwrap() error {
+	return e.Err
+}
+
+func NewHistoryWriter(writer *Writer, historyIndex string, options ...bool) *HistoryWriter {
+	var diff bool
+	if len(options) > 0 {
+		diff = options[0]
+	}
+	return &HistoryWriter{Writer: writer, HistoryIndex: historyIndex, Diff: diff}
+}
+
+// CreateHistoryIndex creates the history index with the mapping of Change.
+func CreateHistoryIndex(ctx context.Context, es *elasticsearch.Client, historyIndex string) (bool, error) {
+	return CreateIndex(ctx, es, historyIndex, map[string]interface{}{"mappings": BuildMapping(reflect.TypeOf(Change{}))})
+}
+
+// Insert records the change with the id of the document, generated by Elasticsearch if the model has no id.
+func (h *HistoryWriter) Insert(ctx context.Context, model interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	r, id, err := h.Writer.insert(ctx, model)
+	if err != nil || r <= 0 {
+		return r, err
+	}
+	return r, historyError(OperationInsert, id, h.recordAfter(ctx, OperationInsert, id, nil))
+}
+
+func (h *HistoryWriter) Update(ctx context.Context, model interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationUpdate, h.id(model), func() (int64, error) {
+		return h.Writer.Update(ctx, model)
+	})
+}
+
+func (h *HistoryWriter) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	id, _ := MapToDBObject(model, h.maps)["_id"].(string)
+	return h.write(ctx, OperationPatch, id, func() (int64, error) {
+		return h.Writer.Patch(ctx, model)
+	})
+}
+
+func (h *HistoryWriter) Save(ctx context.Context, model interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationSave, h.id(model), func() (int64, error) {
+		return h.Writer.Save(ctx, model)
+	})
+}
+
+func (h *HistoryWriter) JsonPatch(ctx context.Context, id interface{}, operations []PatchOperation) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationPatch, id.(string), func() (int64, error) {
+		return h.Writer.JsonPatch(ctx, id, operations)
+	})
+}
+
+func (h *HistoryWriter) MergePatch(ctx context.Context, id interface{}, patch map[string]interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationPatch, id.(string), func() (int64, error) {
+		return h.Writer.MergePatch(ctx, id, patch)
+	})
+}
+
+func (h *HistoryWriter) Delete(ctx context.Context, id interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationDelete, id.(string), func() (int64, error) {
+		return h.Writer.Delete(ctx, id)
+	})
+}
+
+func (h *HistoryWriter) Restore(ctx context.Context, id interface{}) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationRestore, id.(string), func() (int64, error) {
+		return h.Writer.Restore(ctx, id)
+	})
+}
+
+func (h *HistoryWriter) UpdateByScript(ctx context.Context, id interface{}, script Script, upsert bool) (int64, error) {
+	ctx = h.context(ctx)
+	return h.write(ctx, OperationScript, id.(string), func() (int64, error) {
+		return h.Writer.UpdateByScript(ctx, id, script, upsert)
+	})
+}
+
+func (h *HistoryWriter) Increment(ctx context.Context, id interface{}, field string, delta interface{}) (int64, error) {
+	return h.UpdateByScript(ctx, id, IncrementScript(h.jsonName(field), delta), false)
+}
+
+func (h *HistoryWriter) Append(ctx context.Context, id interface{}, field string, values ...interface{}) (int64, error) {
+	return h.UpdateByScript(ctx, id, AppendScript(h.jsonName(field), false, values...), false)
+}
+
+func (h *HistoryWriter) AddToSet(ctx context.Context, id interface{}, field string, values ...interface{}) (int64, error) {
+	return h.UpdateByScript(ctx, id, AppendScript(h.jsonName(field), true, values...), false)
+}
+
+func (h *HistoryWriter) Remove(ctx context.Context, id interface{}, field string, values ...interface{}) (int64, error) {
+	return h.UpdateByScript(ctx, id, RemoveScript(h.jsonName(field), values...), false)
+}
+
+func (h *HistoryWriter) SetIfAbsent(ctx context.Context, id interface{}, field string, value interface{}) (int64, error) {
+	return h.UpdateByScript(ctx, id, SetIfAbsentScript(h.jsonName(field), value), false)
+}
+
+// Purge deletes permanently the documents soft-deleted before the time, by pages of 1000 documents, and records each deleted document as a Change.
+// It returns the number of deleted documents; on an error, the documents of the previous pages are already purged and recorded.
+// If the delete of a page has failures, the documents of the page which are deleted are recorded, and the number of deleted documents is returned with the *ByQueryError.
+func (h *HistoryWriter) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ctx = h.context(ctx)
+	query, err := purgeQuery(h.SoftDelete, before)
+	if err != nil {
+		return -1, err
+	}
+	var deleted int64
+	for {
+		sources, err := searchSources(ctx, h.client, h.indexName, query, purgePageSize)
+		if err != nil {
+			return -1, err
+		}
+		if len(sources) == 0 {
+			return deleted, nil
+		}
+		ids := make([]string, 0, len(sources))
+		for id := range sources {
+			ids = append(ids, id)
+		}
+		page := map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{query, map[string]interface{}{"ids": map[string]interface{}{"values": ids}}},
+			},
+		}
+		r, failure := DeleteByQuery(ctx, h.client, h.indexName, page, ByQueryOptions{Refresh: true})
+		if r == nil {
+			return -1, failure
+		}
+		deleted += r.Deleted
+		// the documents restored meanwhile are not deleted, nor recorded
+		remaining, err := h.sources(ctx, ids, []string{h.SoftDelete.Field})
+		if err != nil {
+			return deleted, &HistoryError{Operation: OperationPurge, Err: err}
+		}
+		for _, id := range ids {
+			if _, ok := remaining[id]; ok {
+				continue
+			}
+			var doc map[string]interface{}
+			if err := CodecFromContext(ctx).Unmarshal(sources[id], &doc); err != nil {
+				return deleted, &HistoryError{Operation: OperationPurge, Id: id, Err: err}
+			}
+			if err := h.record(ctx, OperationPurge, id, doc, nil); err != nil {
+				return deleted, &HistoryError{Operation: OperationPurge, Id: id, Err: err}
+			}
+		}
+		if failure != nil {
+			return deleted, failure
+		}
+		// a page where nothing is deleted, such as when its documents are restored meanwhile, would be found again
+		if r.Deleted == 0 || len(sources) < purgePageSize {
+			return deleted, nil
+		}
+	}
+}
+
+// History returns the changes of the document, the most recent first. The optional parameter is the maximum number of changes, 100 by default.
+// The changes are visible after the refresh of the history index, every second by default.
+func (h *HistoryWriter) History(ctx context.Context, id string, options ...int) ([]Change, error) {
+	ctx = h.context(ctx)
+	return GetHistory(ctx, h.client, h.HistoryIndex, h.indexName, id, options...)
+}
+
+// GetHistory returns the changes of the document of the index, the most recent first. The optional parameter is the maximum number of changes, 100 by default.
+func GetHistory(ctx context.Context, es *elasticsearch.Client, historyIndex string, indexName string, id string, options ...int) ([]Change, error) {
+	size := 100
+	if len(options) > 0 && options[0] > 0 {
+		size = options[0]
+	}
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"id": id}},
+					map[string]interface{}{"term": map[string]interface{}{"index": indexName}},
+				},
+			},
+		},
+		"sort": []interface{}{map[string]interface{}{"time": "desc"}},
+		"size": size,
+	}
+	var changes []Change
+	if _, err := FindAndDecode(ctx, es, []string{historyIndex}, query, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (h *HistoryWriter) id(model interface{}) string {
+	if len(h.idIndex) == 0 {
+		return ""
+	}
+	return getString(model, h.idIndex)
+}
+
+// write loads the document before the write and records the change after a successful write.
+func (h *HistoryWriter) write(ctx context.Context, operation string, id string, do func() (int64, error)) (int64, error) {
+	before, err := h.source(ctx, id)
+	if err != nil {
+		return -1, err
+	}
+	r, err := do()
+	if err != nil || r <= 0 {
+		return r, err
+	}
+	return r, historyError(operation, id, h.recordAfter(ctx, operation, id, before))
+}
+
+func historyError(operation string, id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &HistoryError{Operation: operation, Id: id, Err: err}
+}
+
+func (h *HistoryWriter) recordAfter(ctx context.Context, operation string, id string, before map[string]interface{}) error {
+	after, err := h.source(ctx, id)
+	if err != nil {
+		return err
+	}
+	return h.record(ctx, operation, id, before, after)
+}
+
+// source returns the _source of the document, or nil if the document does not exist.
+func (h *HistoryWriter) source(ctx context.Context, id string) (map[string]interface{}, error) {
+	if len(id) == 0 {
+		return nil, nil
+	}
+	sources, err := h.sources(ctx, []string{id}, nil)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := sources[id]
+	if !ok {
+		return nil, nil
+	}
+	var doc map[string]interface{}
+	if err := CodecFromContext(ctx).Unmarshal(source, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (h *HistoryWriter) record(ctx context.Context, operation string, id string, before map[string]interface{}, after map[string]interface{}) error {
+	fields := changedFields(before, after)
+	if h.Diff {
+		before, after = onlyFields(before, fields), onlyFields(after, fields)
+	}
+	var actor string
+	if h.GetActor != nil {
+		actor = h.GetActor(ctx)
+	} else {
+		actor = auditUser(ctx)
+	}
+	change := Change{Id: id, Index: h.indexName, Operation: operation, Fields: fields, Before: before, After: after, Actor: actor, Time: auditNow(ctx)}
+	req := esapi.IndexRequest{
+		Index: h.HistoryIndex,
+		Body:  NewReader(ctx, change),
+	}
+	res, err := req.Do(ctx, h.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return errors.New("response error")
+	}
+	return nil
+}
+
+// changedFields returns the top level fields which differ between the documents, sorted by name.
+func changedFields(before map[string]interface{}, after map[string]interface{}) []string {
+	var fields []string
+	for k, v := range before {
+		if w, ok := after[k]; !ok || !jsonEqual(v, w) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func jsonEqual(a interface{}, b interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
+
+func onlyFields(doc map[string]interface{}, fields []string) map[string]interface{} {
+	if doc == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if v, ok := doc[field]; ok {
+			result[field] = v
+		}
+	}
+	return result
+}
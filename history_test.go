@@ -0,0 +1,41 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type event struct {
+	Name      string `json:"name"`
+	CreatedBy string `json:"createdBy" es:",createdBy"`
+}
+
+func TestHistoryInsertWithoutId(t *testing.T) {
+	transport := &fakeTransport{respond: func(req *http.Request) (int, string) {
+		if strings.HasSuffix(req.URL.Path, "/_mget") {
+			return http.StatusOK, `{"docs":[{"_id":"generated","found":true,"_source":{"name":"login","createdBy":"u1"}}]}`
+		}
+		return http.StatusCreated, `{"_id":"generated","_version":1,"result":"created","_shards":{"successful":1}}`
+	}}
+	writer := NewHistoryWriter(NewWriter(newTestClient(t, transport), "events", reflect.TypeOf(event{})), "history")
+	ctx := context.WithValue(context.Background(), "userId", "u1")
+	if r, err := writer.Insert(ctx, &event{Name: "login"}); err != nil || r != 1 {
+		t.Fatalf("Insert: %d, %v", r, err)
+	}
+	if len(transport.requests) != 3 {
+		t.Fatalf("requests %v", transport.requests)
+	}
+	if r := transport.requests[0]; r.Method != http.MethodPost || r.Path != "/events/_doc" {
+		t.Errorf("insert %s %s, expected a document with a generated id", r.Method, r.Path)
+	}
+	if created := decodeBody(t, transport.requests[0].Body); created["createdBy"] != "u1" {
+		t.Errorf("document %v, expected createdBy u1", created)
+	}
+	change := decodeBody(t, transport.requests[2].Body)
+	if change["id"] != "generated" || change["operation"] != OperationInsert {
+		t.Errorf("change %v, expected the insert of the generated id", change)
+	}
+}
@@ -0,0 +1,139 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+)
+
+func IndexExists(ctx context.Context, es *elasticsearch.Client, indexName string) (bool, error) {
+	req := esapi.IndicesExistsRequest{
+		Index: []string{indexName},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return true, nil
+}
+
+func CreateIndex(ctx context.Context, es *elasticsearch.Client, indexName string, body map[string]interface{}) (bool, error) {
+	req := esapi.IndicesCreateRequest{
+		Index: indexName,
+	}
+	if body != nil {
+		req.Body = NewReader(ctx, body)
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func DeleteIndex(ctx context.Context, es *elasticsearch.Client, indexName string) (bool, error) {
+	req := esapi.IndicesDeleteRequest{
+		Index: []string{indexName},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func PutMapping(ctx context.Context, es *elasticsearch.Client, indexName string, mapping map[string]interface{}) (bool, error) {
+	req := esapi.IndicesPutMappingRequest{
+		Index: []string{indexName},
+		Body:  NewReader(ctx, mapping),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func PutAlias(ctx context.Context, es *elasticsearch.Client, indexName string, alias string, options ...map[string]interface{}) (bool, error) {
+	req := esapi.IndicesPutAliasRequest{
+		Index: []string{indexName},
+		Name:  alias,
+	}
+	if len(options) > 0 && options[0] != nil {
+		req.Body = NewReader(ctx, options[0])
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+// Reindex copies the documents of the source index into the dest index and waits for completion.
+// The optional script is applied to every document, for example {"source": "ctx._source.remove('temp')"}. If some documents fail, the total is returned with a *ByQueryError.
+func Reindex(ctx context.Context, es *elasticsearch.Client, source string, dest string, options ...map[string]interface{}) (int64, error) {
+	body := map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": dest},
+	}
+	if len(options) > 0 && options[0] != nil {
+		body["script"] = options[0]
+	}
+	refresh := true
+	wait := true
+	req := esapi.ReindexRequest{
+		Body:              NewReader(ctx, body),
+		Refresh:           &refresh,
+		WaitForCompletion: &wait,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return -1, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return -1, errors.New("response error")
+	}
+	r, err := decodeByQueryResult(ctx, res)
+	if r == nil {
+		return -1, err
+	}
+	return r.Total, err
+}
+
+func IsAcknowledged(ctx context.Context, res *esapi.Response) (bool, error) {
+	var r map[string]interface{}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return false, err
+	}
+	acknowledged, _ := r["acknowledged"].(bool)
+	return acknowledged, nil
+}
@@ -9,7 +9,10 @@ import (
 type Inserter struct {
 	client    *es.Client
 	indexName string
+	GetIndex  func(model interface{}) string
+	Pipeline  string
 	Map       func(ctx context.Context, model interface{}) (interface{}, error)
+	Audit     *Audit
 }
 
 func NewInserter(client *es.Client, indexName string, options ...func(context.Context, interface{}) (interface{}, error)) *Inserter {
@@ -20,16 +23,29 @@ func NewInserter(client *es.Client, indexName string, options ...func(context.Co
 	return &Inserter{client: client, indexName: indexName, Map: mp}
 }
 
+func NewInserterWithIndex(client *es.Client, getIndex func(model interface{}) string, options ...func(context.Context, interface{}) (interface{}, error)) *Inserter {
+	inserter := NewInserter(client, "", options...)
+	inserter.GetIndex = getIndex
+	return inserter
+}
+
 func (w *Inserter) Write(ctx context.Context, model interface{}) error {
+	ctx = withDefaultAudit(ctx, w.Audit)
+	model = SetAuditFields(ctx, model, true)
 	modelType := reflect.TypeOf(model)
+	indexName := w.indexName
+	if w.GetIndex != nil {
+		indexName = w.GetIndex(model)
+	}
 	if w.Map != nil {
+		id := documentId(modelType, model)
 		m2, er0 := w.Map(ctx, model)
 		if er0 != nil {
 			return er0
 		}
-		_, er1 := InsertOne(ctx, w.client, w.indexName, modelType, m2)
+		_, er1 := insertOne(ctx, w.client, indexName, modelType, id, m2, getPipeline(ctx, w.Pipeline))
 		return er1
 	}
-	_, er2 := InsertOne(ctx, w.client, w.indexName, modelType, model)
+	_, er2 := InsertOne(ctx, w.client, indexName, modelType, model, getPipeline(ctx, w.Pipeline))
 	return er2
 }
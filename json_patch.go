@@ -0,0 +1,236 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is an operation of a JSON Patch (RFC 6902): "add", "remove", "replace", "move" or "test". Path and From are JSON pointers (RFC 6901) on the json names of the model, such as "/address/city" or "/items/0"; "-" appends to an array.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+const patchFunctions = "def patchParent(def doc, List path) { def c = doc; for (int i = 0; i < path.size() - 1; i++) { if (c instanceof List) { c = c.get(Integer.parseInt(path.get(i))) } else if (c instanceof Map && c.containsKey(path.get(i))) { c = c.get(path.get(i)) } else { c = null } if (c == null) { throw new IllegalArgumentException('path not found: /' + String.join('/', path)) } } return c } " +
+	"def patchGet(def doc, List path) { def p = patchParent(doc, path); String k = path.get(path.size() - 1); if (p instanceof List) { return p.get(Integer.parseInt(k)) } if (!p.containsKey(k)) { throw new IllegalArgumentException('path not found: /' + String.join('/', path)) } return p.get(k) } " +
+	"def patchRemove(def doc, List path) { def p = patchParent(doc, path); String k = path.get(path.size() - 1); if (p instanceof List) { return p.remove(Integer.parseInt(k)) } if (!p.containsKey(k)) { throw new IllegalArgumentException('path not found: /' + String.join('/', path)) } return p.remove(k) } " +
+	"void patchAdd(def doc, List path, def value) { def p = patchParent(doc, path); String k = path.get(path.size() - 1); if (p instanceof List) { if (k == '-') { p.add(value) } else { p.add(Integer.parseInt(k), value) } } else { p.put(k, value) } } " +
+	"void patchReplace(def doc, List path, def value) { def p = patchParent(doc, path); String k = path.get(path.size() - 1); if (p instanceof List) { p.set(Integer.parseInt(k), value) } else if (p.containsKey(k)) { p.put(k, value) } else { throw new IllegalArgumentException('path not found: /' + String.join('/', path)) } } "
+
+const jsonPatchSource = patchFunctions +
+	"for (op in params.ops) { if (op.op == 'add') { patchAdd(ctx._source, op.path, op.value) } else if (op.op == 'remove') { patchRemove(ctx._source, op.path) } else if (op.op == 'replace') { patchReplace(ctx._source, op.path, op.value) } " +
+	"else if (op.op == 'move') { patchAdd(ctx._source, op.path, patchRemove(ctx._source, op.from)) } else if (op.op == 'test') { if (patchGet(ctx._source, op.path) != op.value) { throw new IllegalArgumentException('test failed: /' + String.join('/', op.path)) } } }"
+
+const mergePatchSource = "void merge(Map target, Map patch) { for (entry in patch.entrySet()) { def k = entry.getKey(); def v = entry.getValue(); if (v == null) { target.remove(k) } else if (v instanceof Map) { def t = target.get(k); if (!(t instanceof Map)) { t = new HashMap(); target.put(k, t) } merge(t, v) } else { target.put(k, v) } } } " +
+	"merge(ctx._source, params.patch)"
+
+// ParseJsonPointer returns the reference tokens of the JSON pointer, unescaping "~1" to "/" and "~0" to "~".
+func ParseJsonPointer(pointer string) ([]string, error) {
+	if len(pointer) == 0 || pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// ValidatePatch checks the operations against the model: the paths must be fields of the model, not its id, and the values must be decodable into the fields.
+// An "add" on a struct field has the same effect as a "replace", since a struct field always exists in the model.
+func ValidatePatch(modelType reflect.Type, operations []PatchOperation) error {
+	for _, op := range operations {
+		switch op.Op {
+		case "add", "replace", "test":
+			t, err := patchPathType(modelType, op.Path, op.Op == "add")
+			if err != nil {
+				return err
+			}
+			if err := validateValue(t, op.Value, op.Path); err != nil {
+				return err
+			}
+		case "remove":
+			if _, err := patchPathType(modelType, op.Path, false); err != nil {
+				return err
+			}
+		case "move":
+			from, err := patchPathType(modelType, op.From, false)
+			if err != nil {
+				return err
+			}
+			to, err := patchPathType(modelType, op.Path, true)
+			if err != nil {
+				return err
+			}
+			if elemType(from) != elemType(to) {
+				return fmt.Errorf("cannot move '%s' of type %s to '%s' of type %s", op.From, from, op.Path, to)
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return fmt.Errorf("cannot move '%s' into itself", op.From)
+			}
+		default:
+			return fmt.Errorf("unsupported patch operation '%s'", op.Op)
+		}
+	}
+	return nil
+}
+
+// ValidateMergePatch checks the merge patch against the model: the keys must be json names of the fields, not the id, and the values must be null or decodable into the fields.
+func ValidateMergePatch(modelType reflect.Type, patch map[string]interface{}) error {
+	meta := GetMetadata(modelType)
+	for key := range patch {
+		if len(meta.Id) > 0 && key == meta.JsonIdName {
+			return fmt.Errorf("cannot patch the document ID '%s'", key)
+		}
+	}
+	return validateMerge(meta.Type, patch, "")
+}
+
+func validateMerge(t reflect.Type, patch map[string]interface{}, prefix string) error {
+	for key, value := range patch {
+		path := prefix + "/" + key
+		ft, err := childType(t, key, path, false)
+		if err != nil {
+			return err
+		}
+		if value == nil || ft == nil {
+			continue
+		}
+		et := ft
+		for et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if sub, ok := value.(map[string]interface{}); ok && (et.Kind() == reflect.Struct || et.Kind() == reflect.Map) {
+			if err := validateMerge(et, sub, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateValue(ft, value, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchPathType returns the type of the value at the path, or nil if the path goes into an interface, which is not checked.
+func patchPathType(modelType reflect.Type, path string, add bool) (reflect.Type, error) {
+	tokens, err := ParseJsonPointer(path)
+	if err != nil {
+		return nil, err
+	}
+	meta := GetMetadata(modelType)
+	if len(meta.Id) > 0 && tokens[0] == meta.JsonIdName {
+		return nil, fmt.Errorf("cannot patch the document ID '%s'", path)
+	}
+	t := meta.Type
+	for i, token := range tokens {
+		if t, err = childType(t, token, path, add && i == len(tokens)-1); err != nil || t == nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func childType(t reflect.Type, token string, path string, add bool) (reflect.Type, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := getStructFields(t)
+		i, ok := fields.byJson[token]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a field of %s", path, t.Name())
+		}
+		return fields.list[i].Type, nil
+	case reflect.Slice, reflect.Array:
+		if token == "-" && add {
+			return t.Elem(), nil
+		}
+		if _, err := strconv.Atoi(token); err != nil {
+			return nil, fmt.Errorf("invalid array index in '%s'", path)
+		}
+		return t.Elem(), nil
+	case reflect.Map:
+		return t.Elem(), nil
+	case reflect.Interface:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("'%s' is not a path of the model", path)
+}
+
+// elemType returns the type without its pointers, since a pointer and its value have the same JSON.
+func elemType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func validateValue(t reflect.Type, value interface{}, path string) error {
+	if t == nil || value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("invalid value of '%s': %s", path, err.Error())
+	}
+	return nil
+}
+
+// JsonPatchScript translates the operations into a painless script; the operations are applied atomically, and a failed "test" fails the update.
+func JsonPatchScript(operations []PatchOperation) (Script, error) {
+	ops := make([]map[string]interface{}, 0, len(operations))
+	for _, op := range operations {
+		path, err := ParseJsonPointer(op.Path)
+		if err != nil {
+			return Script{}, err
+		}
+		m := map[string]interface{}{"op": op.Op, "path": path, "value": op.Value}
+		if op.Op == "move" {
+			from, err := ParseJsonPointer(op.From)
+			if err != nil {
+				return Script{}, err
+			}
+			m["from"] = from
+		}
+		ops = append(ops, m)
+	}
+	return NewScript(jsonPatchSource, map[string]interface{}{"ops": ops}), nil
+}
+
+// MergePatchScript translates the merge patch (RFC 7386) into a painless script: a null removes the field, an object is merged into the field, and another value replaces the field.
+func MergePatchScript(patch map[string]interface{}) Script {
+	return NewScript(mergePatchSource, map[string]interface{}{"patch": patch})
+}
+
+// JsonPatchOne applies the JSON Patch to the document, after validating the operations against the model type.
+func JsonPatchOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, documentID string, operations []PatchOperation) (int64, error) {
+	if err := ValidatePatch(modelType, operations); err != nil {
+		return -1, err
+	}
+	script, err := JsonPatchScript(operations)
+	if err != nil {
+		return -1, err
+	}
+	return ScriptOne(ctx, es, indexName, documentID, script)
+}
+
+// MergePatchOne applies the merge patch to the document, after validating it against the model type.
+func MergePatchOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, documentID string, patch map[string]interface{}) (int64, error) {
+	if err := ValidateMergePatch(modelType, patch); err != nil {
+		return -1, err
+	}
+	return ScriptOne(ctx, es, indexName, documentID, MergePatchScript(patch))
+}
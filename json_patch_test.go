@@ -0,0 +1,139 @@
+package elasticsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+type patchAddress struct {
+	City  string   `json:"city"`
+	Lines []string `json:"lines"`
+}
+
+type patchUser struct {
+	Id       string                 `json:"id" bson:"_id"`
+	Name     string                 `json:"name"`
+	Age      int                    `json:"age"`
+	Address  *patchAddress          `json:"address"`
+	Previous []patchAddress         `json:"previous"`
+	Tags     []string               `json:"tags"`
+	Settings map[string]int         `json:"settings"`
+	Extra    map[string]interface{} `json:"extra"`
+	Data     interface{}            `json:"data"`
+}
+
+func TestParseJsonPointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		tokens  []string
+		invalid bool
+	}{
+		{"/name", []string{"name"}, false},
+		{"/address/city", []string{"address", "city"}, false},
+		{"/a~1b", []string{"a/b"}, false},
+		{"/m~0n", []string{"m~n"}, false},
+		{"/~01", []string{"~1"}, false},
+		{"/", []string{""}, false},
+		{"", nil, true},
+		{"name", nil, true},
+	}
+	for _, tt := range tests {
+		tokens, err := ParseJsonPointer(tt.pointer)
+		if (err != nil) != tt.invalid {
+			t.Errorf("%q: error %v, expected invalid %v", tt.pointer, err, tt.invalid)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, tt.tokens) {
+			t.Errorf("%q: tokens %q, expected %q", tt.pointer, tokens, tt.tokens)
+		}
+	}
+}
+
+func TestValidatePatch(t *testing.T) {
+	modelType := reflect.TypeOf(patchUser{})
+	tests := []struct {
+		name    string
+		op      PatchOperation
+		invalid bool
+	}{
+		{"replace", PatchOperation{Op: "replace", Path: "/name", Value: "peter"}, false},
+		{"add to a struct field", PatchOperation{Op: "add", Path: "/address/city", Value: "Hanoi"}, false},
+		{"append to an array", PatchOperation{Op: "add", Path: "/tags/-", Value: "new"}, false},
+		{"insert into an array", PatchOperation{Op: "add", Path: "/tags/0", Value: "first"}, false},
+		{"replace the end of an array", PatchOperation{Op: "replace", Path: "/tags/-", Value: "last"}, true},
+		{"invalid array index", PatchOperation{Op: "remove", Path: "/tags/x"}, true},
+		{"add to a map", PatchOperation{Op: "add", Path: "/settings/a~1b", Value: 1}, false},
+		{"map value type", PatchOperation{Op: "add", Path: "/settings/size", Value: "big"}, true},
+		{"into an interface", PatchOperation{Op: "add", Path: "/data/any/path", Value: []int{1}}, false},
+		{"into a map of interfaces", PatchOperation{Op: "add", Path: "/extra/key", Value: true}, false},
+		{"the id", PatchOperation{Op: "replace", Path: "/id", Value: "2"}, true},
+		{"remove the id", PatchOperation{Op: "remove", Path: "/id"}, true},
+		{"unknown field", PatchOperation{Op: "replace", Path: "/unknown", Value: 1}, true},
+		{"into a string", PatchOperation{Op: "add", Path: "/name/first", Value: "peter"}, true},
+		{"string into an int", PatchOperation{Op: "replace", Path: "/age", Value: "ten"}, true},
+		{"number into a string", PatchOperation{Op: "test", Path: "/name", Value: 10}, true},
+		{"object into an array", PatchOperation{Op: "replace", Path: "/tags", Value: map[string]interface{}{"a": 1}}, true},
+		{"object into a struct", PatchOperation{Op: "replace", Path: "/address", Value: map[string]interface{}{"city": "Hue"}}, false},
+		{"null value", PatchOperation{Op: "replace", Path: "/age", Value: nil}, false},
+		{"move", PatchOperation{Op: "move", From: "/address", Path: "/previous/-"}, false},
+		{"move into itself", PatchOperation{Op: "move", From: "/previous", Path: "/previous/0"}, true},
+		{"move into a child of itself", PatchOperation{Op: "move", From: "/address", Path: "/address/lines"}, true},
+		{"move to another type", PatchOperation{Op: "move", From: "/name", Path: "/age"}, true},
+		{"move the id", PatchOperation{Op: "move", From: "/id", Path: "/name"}, true},
+		{"invalid pointer", PatchOperation{Op: "remove", Path: "name"}, true},
+		{"unsupported operation", PatchOperation{Op: "copy", From: "/name", Path: "/name"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePatch(modelType, []PatchOperation{tt.op}); (err != nil) != tt.invalid {
+				t.Errorf("error %v, expected invalid %v", err, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestValidateMergePatch(t *testing.T) {
+	modelType := reflect.TypeOf(patchUser{})
+	tests := []struct {
+		name    string
+		patch   map[string]interface{}
+		invalid bool
+	}{
+		{"fields", map[string]interface{}{"name": "peter", "age": 10}, false},
+		{"remove", map[string]interface{}{"name": nil}, false},
+		{"nested object", map[string]interface{}{"address": map[string]interface{}{"city": "Hue", "lines": nil}}, false},
+		{"nested type mismatch", map[string]interface{}{"address": map[string]interface{}{"city": 1}}, true},
+		{"nested unknown field", map[string]interface{}{"address": map[string]interface{}{"zip": "1"}}, true},
+		{"the id", map[string]interface{}{"id": "2"}, true},
+		{"type mismatch", map[string]interface{}{"age": "ten"}, true},
+		{"map values", map[string]interface{}{"settings": map[string]interface{}{"size": 1}}, false},
+		{"map value type", map[string]interface{}{"settings": map[string]interface{}{"size": "big"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMergePatch(modelType, tt.patch); (err != nil) != tt.invalid {
+				t.Errorf("error %v, expected invalid %v", err, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestJsonPatchScript(t *testing.T) {
+	script, err := JsonPatchScript([]PatchOperation{
+		{Op: "add", Path: "/tags/-", Value: "x"},
+		{Op: "move", From: "/a~1b", Path: "/c~0d"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{"op": "add", "path": []string{"tags", "-"}, "value": "x"},
+		{"op": "move", "path": []string{"c~d"}, "value": nil, "from": []string{"a/b"}},
+	}
+	if ops := script.Params["ops"]; !reflect.DeepEqual(ops, expected) {
+		t.Errorf("ops %v, expected %v", ops, expected)
+	}
+	if _, err := JsonPatchScript([]PatchOperation{{Op: "move", From: "a", Path: "/b"}}); err == nil {
+		t.Error("expected an error for an invalid from pointer")
+	}
+}
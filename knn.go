@@ -0,0 +1,97 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// KnnQuery is an approximate k-nearest neighbor search on a dense_vector field.
+// The knn option of the search API requires Elasticsearch 8.4 or later, and Similarity requires 8.8 or later. Since this package uses the 7.x client,
+// the client of an 8.x cluster must be created with the compatibility mode, see Config.EnableCompatibilityMode.
+type KnnQuery struct {
+	Field         string                 `json:"field"`
+	QueryVector   []float32              `json:"query_vector"`
+	K             int                    `json:"k"`
+	NumCandidates int                    `json:"num_candidates"`
+	Filter        map[string]interface{} `json:"filter,omitempty"`
+	Boost         float64                `json:"boost,omitempty"`
+	Similarity    *float64               `json:"similarity,omitempty"`
+}
+
+// NewKnnQuery creates a kNN query. The filter is the body of a search request, as built by SearchBuilder.BuildQuery, or only its "query" clause; it can be nil.
+func NewKnnQuery(field string, vector []float32, k int, numCandidates int, filter map[string]interface{}) KnnQuery {
+	knn := KnnQuery{Field: field, QueryVector: vector, K: k, NumCandidates: numCandidates}
+	if len(filter) > 0 {
+		knn.Filter, _ = BuildQueryBody(filter)["query"].(map[string]interface{})
+	}
+	if knn.NumCandidates < k {
+		knn.NumCandidates = k
+	}
+	return knn
+}
+
+// KnnSearch returns the k nearest documents of the indices, decoded into results, a pointer to a slice; the field of the model tagged es:"_score" receives the similarity score.
+// The indices can be wildcards or date math expressions; the indices which do not exist are ignored when there are more than one.
+func KnnSearch(ctx context.Context, es *elasticsearch.Client, indices []string, knn KnnQuery, results interface{}, options ...Projection) (int64, error) {
+	body := map[string]interface{}{
+		"knn":  knn,
+		"size": knn.K,
+	}
+	return doVectorSearch(ctx, es, indices, body, results, options...)
+}
+
+// HybridSearch combines the kNN query with a query, such as a bool query: the score of a hit is the sum of its kNN score and of its query score, each multiplied by its boost.
+// The query is the body of a search request or only its "query" clause.
+func HybridSearch(ctx context.Context, es *elasticsearch.Client, indices []string, knn KnnQuery, query map[string]interface{}, size int, results interface{}, options ...Projection) (int64, error) {
+	body := BuildQueryBody(query)
+	body["knn"] = knn
+	body["size"] = size
+	return doVectorSearch(ctx, es, indices, body, results, options...)
+}
+
+func doVectorSearch(ctx context.Context, es *elasticsearch.Client, indices []string, body map[string]interface{}, results interface{}, options ...Projection) (int64, error) {
+	req := esapi.SearchRequest{
+		Index: pathIndices(indices...),
+		Body:  NewReader(ctx, body),
+	}
+	if len(indices) > 1 {
+		ignoreUnavailable := true
+		req.IgnoreUnavailable = &ignoreUnavailable
+	}
+	ApplyProjection(&req, getProjection(options))
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, errors.New("response error")
+	}
+	var r SearchResponse
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return 0, err
+	}
+	return r.Hits.Total.Value, DecodeSearchHits(ctx, r.Hits.Hits, results)
+}
+
+// SearchKnn runs a kNN search filtered by the query of the search model.
+func (b *SearchBuilder) SearchKnn(ctx context.Context, sm interface{}, field string, vector []float32, k int, numCandidates int, results interface{}) (int64, error) {
+	ctx = b.context(ctx)
+	ctx = withEncryptedType(ctx, b.ModelType)
+	query, err := b.buildQuery(ctx, sm)
+	if err != nil {
+		return 0, err
+	}
+	knn := NewKnnQuery(field, vector, k, numCandidates, query)
+	var projections []Projection
+	if b.Projection != nil {
+		projections = append(projections, *b.Projection)
+	}
+	count, err := KnnSearch(ctx, b.Client, b.indices(sm), knn, results, projections...)
+	if err == nil && b.Map != nil {
+		_, err = MapModels(ctx, results, b.Map)
+	}
+	return count, err
+}
@@ -0,0 +1,258 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+	"reflect"
+)
+
+type RolloverConditions struct {
+	MaxAge              string `json:"max_age,omitempty"`
+	MaxSize             string `json:"max_size,omitempty"`
+	MaxPrimaryShardSize string `json:"max_primary_shard_size,omitempty"`
+	MaxDocs             int64  `json:"max_docs,omitempty"`
+}
+
+type LifecyclePhase struct {
+	MinAge  string                 `json:"min_age,omitempty"`
+	Actions map[string]interface{} `json:"actions"`
+}
+
+type LifecyclePolicy struct {
+	Phases map[string]LifecyclePhase `json:"phases"`
+}
+
+type RolloverResult struct {
+	OldIndex   string          `json:"old_index"`
+	NewIndex   string          `json:"new_index"`
+	RolledOver bool            `json:"rolled_over"`
+	DryRun     bool            `json:"dry_run"`
+	Conditions map[string]bool `json:"conditions"`
+}
+
+type LifecycleStep struct {
+	Index      string                 `json:"index"`
+	Managed    bool                   `json:"managed"`
+	Policy     string                 `json:"policy,omitempty"`
+	Phase      string                 `json:"phase,omitempty"`
+	Action     string                 `json:"action,omitempty"`
+	Step       string                 `json:"step,omitempty"`
+	Age        string                 `json:"age,omitempty"`
+	FailedStep string                 `json:"failed_step,omitempty"`
+	StepInfo   map[string]interface{} `json:"step_info,omitempty"`
+}
+
+// NewLifecyclePolicy builds a policy which rolls the hot index over by the conditions, moves it to the warm phase after warmAfter and deletes it after deleteAfter.
+// The warm and delete phases are skipped when their min age is empty.
+func NewLifecyclePolicy(rollover RolloverConditions, warmAfter string, deleteAfter string) LifecyclePolicy {
+	phases := map[string]LifecyclePhase{
+		"hot": {
+			MinAge: "0ms",
+			Actions: map[string]interface{}{
+				"rollover":     rollover,
+				"set_priority": map[string]interface{}{"priority": 100},
+			},
+		},
+	}
+	if len(warmAfter) > 0 {
+		phases["warm"] = LifecyclePhase{
+			MinAge: warmAfter,
+			Actions: map[string]interface{}{
+				"forcemerge":   map[string]interface{}{"max_num_segments": 1},
+				"set_priority": map[string]interface{}{"priority": 50},
+			},
+		}
+	}
+	if len(deleteAfter) > 0 {
+		phases["delete"] = LifecyclePhase{
+			MinAge:  deleteAfter,
+			Actions: map[string]interface{}{"delete": map[string]interface{}{}},
+		}
+	}
+	return LifecyclePolicy{Phases: phases}
+}
+
+func PutLifecyclePolicy(ctx context.Context, es *elasticsearch.Client, name string, policy LifecyclePolicy) (bool, error) {
+	req := esapi.ILMPutLifecycleRequest{
+		Policy: name,
+		Body:   NewReader(ctx, map[string]interface{}{"policy": policy}),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func GetLifecyclePolicy(ctx context.Context, es *elasticsearch.Client, name string) (*LifecyclePolicy, error) {
+	req := esapi.ILMGetLifecycleRequest{
+		Policy: name,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r map[string]struct {
+		Policy LifecyclePolicy `json:"policy"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	if p, ok := r[name]; ok {
+		return &p.Policy, nil
+	}
+	return nil, nil
+}
+
+func DeleteLifecyclePolicy(ctx context.Context, es *elasticsearch.Client, name string) (bool, error) {
+	req := esapi.ILMDeleteLifecycleRequest{
+		Policy: name,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+// AttachLifecyclePolicy sets the lifecycle policy of an existing index. The rollover alias is required only when the policy has a rollover action.
+func AttachLifecyclePolicy(ctx context.Context, es *elasticsearch.Client, indexName string, policy string, options ...string) (bool, error) {
+	settings := map[string]interface{}{"index.lifecycle.name": policy}
+	if len(options) > 0 && len(options[0]) > 0 {
+		settings["index.lifecycle.rollover_alias"] = options[0]
+	}
+	req := esapi.IndicesPutSettingsRequest{
+		Index: []string{indexName},
+		Body:  NewReader(ctx, settings),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func AliasExists(ctx context.Context, es *elasticsearch.Client, alias string) (bool, error) {
+	req := esapi.IndicesExistsAliasRequest{
+		Name: []string{alias},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return true, nil
+}
+
+// BootstrapRolloverAlias creates the first index "<alias>-000001" with the alias as its write index, managed by the lifecycle policy if it is not empty.
+// It returns false if the alias already exists, so that it can be called at every start-up.
+func BootstrapRolloverAlias(ctx context.Context, es *elasticsearch.Client, alias string, policy string) (bool, error) {
+	exist, err := AliasExists(ctx, es, alias)
+	if err != nil || exist {
+		return false, err
+	}
+	body := map[string]interface{}{
+		"aliases": map[string]interface{}{
+			alias: map[string]interface{}{"is_write_index": true},
+		},
+	}
+	if len(policy) > 0 {
+		body["settings"] = map[string]interface{}{
+			"index.lifecycle.name":           policy,
+			"index.lifecycle.rollover_alias": alias,
+		}
+	}
+	return CreateIndex(ctx, es, fmt.Sprintf("%s-000001", alias), body)
+}
+
+// Rollover creates a new write index for the alias. Without conditions, the alias is rolled over unconditionally.
+func Rollover(ctx context.Context, es *elasticsearch.Client, alias string, conditions *RolloverConditions, options ...bool) (*RolloverResult, error) {
+	req := esapi.IndicesRolloverRequest{
+		Alias: alias,
+	}
+	if conditions != nil {
+		req.Body = NewReader(ctx, map[string]interface{}{"conditions": conditions})
+	}
+	if len(options) > 0 && options[0] {
+		dryRun := true
+		req.DryRun = &dryRun
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var result RolloverResult
+	if err := decode(ctx, res.Body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExplainLifecycle returns the current lifecycle phase, action and step of the index.
+func ExplainLifecycle(ctx context.Context, es *elasticsearch.Client, indexName string) (*LifecycleStep, error) {
+	req := esapi.ILMExplainLifecycleRequest{
+		Index: indexName,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		Indices map[string]LifecycleStep `json:"indices"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	if step, ok := r.Indices[indexName]; ok {
+		return &step, nil
+	}
+	return nil, nil
+}
+
+// NewRolloverWriter creates a writer on a rollover alias: new documents are written to the write index of the alias,
+// while the documents to load, update, patch or delete are looked up in the index holding them.
+func NewRolloverWriter(client *elasticsearch.Client, alias string, modelType reflect.Type, options ...string) *Writer {
+	writer := NewWriter(client, alias, modelType, options...)
+	writer.alias = true
+	return writer
+}
@@ -2,6 +2,7 @@ package elasticsearch
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/elastic/go-elasticsearch/v7"
 	"log"
 	"reflect"
@@ -12,28 +13,42 @@ type Loader struct {
 	indexName  string
 	modelType  reflect.Type
 	jsonIdName string
-	idIndex    int
+	idIndex    []int
+	alias      bool
+	Codec      Codec
+	Encryptor  *Encryptor
+	SoftDelete *SoftDelete
 	Map        func(ctx context.Context, model interface{}) (interface{}, error)
 }
 
 func NewLoader(client *elasticsearch.Client, indexName string, modelType reflect.Type, options ...func(context.Context, interface{}) (interface{}, error)) *Loader {
-	idIndex, _, jsonIdName := FindIdField(modelType)
-	if idIndex < 0 {
+	meta := GetMetadata(modelType)
+	if len(meta.Id) == 0 {
 		log.Println(modelType.Name() + " repository can't use functions that need Id value (Ex Load, Exist, Save, Update) because don't have any fields of " + modelType.Name() + " struct define _id bson tag.")
 	}
 	var mp func(context.Context, interface{}) (interface{}, error)
 	if len(options) > 0 {
 		mp = options[0]
 	}
-	return &Loader{client: client, indexName: indexName, modelType: modelType, jsonIdName: jsonIdName, idIndex: idIndex, Map: mp}
+	return &Loader{client: client, indexName: indexName, modelType: modelType, jsonIdName: meta.JsonIdName, idIndex: meta.Id, SoftDelete: NewSoftDelete(modelType), Map: mp}
 }
 
 func (m *Loader) Id() string {
 	return m.indexName
 }
 
+// context returns the context with the codec and the encryptor of the loader, unless the context already has them.
+func (m *Loader) context(ctx context.Context) context.Context {
+	ctx = withDefaultCodec(ctx, m.Codec)
+	return withDefaultEncryptor(ctx, m.Encryptor)
+}
+
 func (m *Loader) All(ctx context.Context) (interface{}, error) {
+	ctx = m.context(ctx)
 	query := BuildQueryMap(m.indexName, nil)
+	if m.SoftDelete.excludes(ctx) {
+		query = m.SoftDelete.Exclude(query)
+	}
 	result, err := Find(ctx, m.client, []string{m.indexName}, query, m.modelType)
 	if m.Map != nil && err == nil && result != nil {
 		return MapModels(ctx, result, m.Map)
@@ -41,12 +56,34 @@ func (m *Loader) All(ctx context.Context) (interface{}, error) {
 	return result, err
 }
 
+// NewAliasLoader creates a loader on an alias or a wildcard targeting several indices, such as a rollover alias.
+func NewAliasLoader(client *elasticsearch.Client, alias string, modelType reflect.Type, options ...func(context.Context, interface{}) (interface{}, error)) *Loader {
+	loader := NewLoader(client, alias, modelType, options...)
+	loader.alias = true
+	return loader
+}
+
+func (m *Loader) index(ctx context.Context, id string) (string, error) {
+	if m.alias {
+		return FindIndexById(ctx, m.client, m.indexName, id)
+	}
+	return m.indexName, nil
+}
+
 func (m *Loader) Load(ctx context.Context, id interface{}) (interface{}, error) {
+	ctx = m.context(ctx)
 	sid := id.(string)
-	r, er1 := FindOneById(ctx, m.client, m.indexName, sid, m.modelType)
+	indexName, er0 := m.index(ctx, sid)
+	if er0 != nil || len(indexName) == 0 {
+		return nil, er0
+	}
+	r, er1 := FindOneById(ctx, m.client, indexName, sid, m.modelType)
 	if er1 != nil {
 		return r, er1
 	}
+	if r != nil && m.SoftDelete.excludes(ctx) && m.SoftDelete.IsDeleted(r) {
+		return nil, nil
+	}
 	if m.Map != nil {
 		r2, er2 := m.Map(ctx, r)
 		if er2 != nil {
@@ -57,9 +94,72 @@ func (m *Loader) Load(ctx context.Context, id interface{}) (interface{}, error)
 	return r, er1
 }
 
+// LoadMany loads the documents by ids, in the order of the ids. It returns a pointer to a slice of the models and the ids which are not found.
+func (m *Loader) LoadMany(ctx context.Context, ids []string) (interface{}, []string, error) {
+	result := reflect.New(reflect.SliceOf(m.modelType)).Interface()
+	missing, err := m.LoadManyAndDecode(ctx, ids, result)
+	if err != nil {
+		return nil, missing, err
+	}
+	return result, missing, nil
+}
+
+// LoadManyAndDecode decodes the documents into result, a pointer to a slice. If the slice is not of the model, only the fields of its elements are loaded from _source.
+func (m *Loader) LoadManyAndDecode(ctx context.Context, ids []string, result interface{}) ([]string, error) {
+	ctx = m.context(ctx)
+	ctx = withEncryptedType(ctx, m.modelType)
+	var sources map[string]json.RawMessage
+	var err error
+	var includes []string
+	if t := reflect.TypeOf(result); t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice && t.Elem().Elem() != m.modelType {
+		includes = GetSourceFields(t.Elem().Elem())
+	}
+	excludes := m.SoftDelete.excludes(ctx)
+	if excludes && len(includes) > 0 {
+		includes = append(includes, m.SoftDelete.Field)
+	}
+	sources, err = m.sources(ctx, ids, includes)
+	if err != nil {
+		return nil, err
+	}
+	if excludes {
+		if err = m.SoftDelete.removeDeleted(CodecFromContext(ctx), sources); err != nil {
+			return nil, err
+		}
+	}
+	missing, err := DecodeSources(ctx, ids, sources, result)
+	if err != nil {
+		return missing, err
+	}
+	if err = decryptModels(ctx, result); err != nil {
+		return missing, err
+	}
+	if m.Map != nil {
+		_, err = MapModels(ctx, result, m.Map)
+	}
+	return missing, err
+}
+
+// LoadAndDecode decodes the document into result. If result is not a pointer to the model, only the fields of result are loaded from _source.
 func (m *Loader) LoadAndDecode(ctx context.Context, id interface{}, result interface{}) (bool, error) {
+	ctx = m.context(ctx)
+	ctx = withEncryptedType(ctx, m.modelType)
 	sid := id.(string)
-	ok, er0 := FindOneByIdAndDecode(ctx, m.client, m.indexName, sid, result)
+	indexName, err := m.index(ctx, sid)
+	if err != nil || len(indexName) == 0 {
+		return false, err
+	}
+	var projections []Projection
+	if t := reflect.TypeOf(result); t != nil && t.Kind() == reflect.Ptr && t.Elem() != m.modelType && t.Elem().Kind() == reflect.Struct {
+		projections = append(projections, NewProjection(t.Elem()))
+	}
+	ok, er0 := FindOneByIdAndDecode(ctx, m.client, indexName, sid, result, projections...)
+	if ok && er0 == nil && m.SoftDelete.excludes(ctx) {
+		deleted, er1 := m.isDeleted(ctx, sid, result)
+		if er1 != nil || deleted {
+			return false, er1
+		}
+	}
 	if ok && er0 == nil && m.Map != nil {
 		_, er2 := m.Map(ctx, result)
 		if er2 != nil {
@@ -70,6 +170,45 @@ func (m *Loader) LoadAndDecode(ctx context.Context, id interface{}, result inter
 }
 
 func (m *Loader) Exist(ctx context.Context, id interface{}) (bool, error) {
+	ctx = m.context(ctx)
 	sid := id.(string)
+	if m.SoftDelete.excludes(ctx) {
+		sources, err := m.sources(ctx, []string{sid}, []string{m.SoftDelete.Field})
+		if err != nil {
+			return false, err
+		}
+		if err = m.SoftDelete.removeDeleted(CodecFromContext(ctx), sources); err != nil {
+			return false, err
+		}
+		return len(sources) > 0, nil
+	}
+	if m.alias {
+		indexName, err := m.index(ctx, sid)
+		return len(indexName) > 0, err
+	}
 	return Exist(ctx, m.client, m.indexName, sid)
 }
+
+func (m *Loader) sources(ctx context.Context, ids []string, includes []string) (map[string]json.RawMessage, error) {
+	if m.alias {
+		return SearchByIds(ctx, m.client, m.indexName, ids, includes)
+	}
+	return MultiGet(ctx, m.client, m.indexName, ids, includes)
+}
+
+// isDeleted checks the soft delete field of the result, or loads it if the result has no such field, such as a projection.
+func (m *Loader) isDeleted(ctx context.Context, id string, result interface{}) (bool, error) {
+	if t := reflect.TypeOf(result); t != nil && t.Kind() == reflect.Ptr {
+		if field, ok := FindStructField(t.Elem(), m.SoftDelete.Field); ok && field.Type == m.SoftDelete.Type {
+			return (&SoftDelete{Field: field.JsonName, Index: field.Index, Type: field.Type}).IsDeleted(result), nil
+		}
+	}
+	sources, err := m.sources(ctx, []string{id}, []string{m.SoftDelete.Field})
+	if err != nil {
+		return false, err
+	}
+	if err = m.SoftDelete.removeDeleted(CodecFromContext(ctx), sources); err != nil {
+		return false, err
+	}
+	return len(sources) == 0, nil
+}
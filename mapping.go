@@ -0,0 +1,125 @@
+package elasticsearch
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BuildMapping generates the mappings of an index from the fields of the model, named by their json tags.
+// The type of a field is derived from its Go type, and can be set by the "es" tag, followed by mapping parameters, for example:
+//
+//	Name    string    `json:"name" es:"text,analyzer:english"`
+//	Items   []Item    `json:"items" es:"nested"`
+//	Comment string    `json:"comment" es:"-"`
+//	Vector  []float32 `json:"vector" es:"dense_vector,dims:384,index:true,similarity:cosine"`
+//
+// The parameters index and similarity of a dense_vector field require Elasticsearch 8.0 or later, see KnnQuery.
+// The fields tagged with metadata, such as es:"_score", are not mapped, see DecodeHits.
+func BuildMapping(modelType reflect.Type) map[string]interface{} {
+	return map[string]interface{}{"properties": BuildProperties(modelType)}
+}
+
+func BuildProperties(modelType reflect.Type) map[string]interface{} {
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	properties := make(map[string]interface{})
+	idField, _ := findBsonField(modelType, "_id")
+	for _, field := range GetStructFields(modelType) {
+		if field.Name == idField.Name {
+			continue
+		}
+		jsonName := field.JsonName
+		tag, ok := field.Tag.Lookup("es")
+		if ok && (tag == "-" || strings.HasPrefix(tag, "_")) {
+			continue
+		}
+		if property := buildProperty(field.Type, tag); property != nil {
+			properties[jsonName] = property
+		}
+		if hasFlag(tag, "encrypted") && hasFlag(tag, "blindIndex") {
+			properties[jsonName+BlindIndexSuffix] = map[string]interface{}{"type": "keyword"}
+		}
+	}
+	return properties
+}
+
+func buildProperty(fieldType reflect.Type, tag string) map[string]interface{} {
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	property := make(map[string]interface{})
+	var esType string
+	if len(tag) > 0 {
+		params := strings.Split(tag, ",")
+		esType = strings.TrimSpace(params[0])
+		for _, param := range params[1:] {
+			kv := strings.SplitN(param, ":", 2)
+			if len(kv) == 2 {
+				property[strings.TrimSpace(kv[0])] = parseParameter(strings.TrimSpace(kv[1]))
+			}
+		}
+	}
+	elemType := fieldType
+	if (fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array) && fieldType.Elem().Kind() != reflect.Uint8 {
+		elemType = fieldType.Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+	}
+	if len(esType) == 0 {
+		esType = esTypeOf(elemType)
+		if len(esType) == 0 {
+			return nil
+		}
+	}
+	property["type"] = esType
+	if (esType == "object" || esType == "nested") && elemType.Kind() == reflect.Struct {
+		property["properties"] = BuildProperties(elemType)
+	}
+	return property
+}
+
+func esTypeOf(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "keyword"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "long"
+	case reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int16, reflect.Uint8:
+		return "short"
+	case reflect.Int8:
+		return "byte"
+	case reflect.Float64:
+		return "double"
+	case reflect.Float32:
+		return "float"
+	case reflect.Slice:
+		return "binary"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return ""
+}
+
+func parseParameter(s string) interface{} {
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package elasticsearch
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Metadata is the reflection metadata of a model, computed once by type: the fields as encoding/json sees them, the id field (bson:"_id"),
+// the version field (es:",version"), the audit fields and the encrypted fields. The flags follow the mapping type in the es tag, such as es:"keyword,encrypted".
+type Metadata struct {
+	Type       reflect.Type
+	Fields     []StructField
+	Id         []int
+	IdName     string
+	JsonIdName string
+	Version    []int
+	JsonNames  map[string]string
+	audit      []auditField
+	encrypted  []encryptedField
+}
+
+var metadataCache sync.Map
+
+func GetMetadata(modelType reflect.Type) *Metadata {
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if m, ok := metadataCache.Load(modelType); ok {
+		return m.(*Metadata)
+	}
+	m := &Metadata{Type: modelType, Fields: GetStructFields(modelType)}
+	if modelType.Kind() == reflect.Struct {
+		if idField, ok := findBsonField(modelType, "_id"); ok {
+			m.Id, m.IdName, m.JsonIdName = idField.Index, idField.Name, idField.JsonName
+		}
+		for _, field := range m.Fields {
+			tag := field.Tag.Get("es")
+			if m.Version == nil && hasFlag(tag, "version") {
+				m.Version = field.Index
+			}
+			if hasFlag(tag, "encrypted") {
+				m.encrypted = append(m.encrypted, encryptedField{field: field, deterministic: hasFlag(tag, "deterministic"), blindIndex: hasFlag(tag, "blindIndex")})
+			}
+			for _, flag := range []string{CreatedAt, UpdatedAt, CreatedBy, UpdatedBy} {
+				if hasFlag(tag, flag) {
+					m.audit = append(m.audit, auditField{flag: flag, field: field})
+				}
+			}
+		}
+		m.JsonNames = MakeMapJson(modelType)
+	}
+	v, _ := metadataCache.LoadOrStore(modelType, m)
+	return v.(*Metadata)
+}
+
+// GetId returns the id of the model, or "" if the model has no id field.
+func (m *Metadata) GetId(model interface{}) string {
+	if len(m.Id) == 0 {
+		return ""
+	}
+	return getString(model, m.Id)
+}
+
+// Body returns the document of the model without its id, as BuildQueryWithoutIdFromObject: every field is sent, even with omitempty, so that an update clears the empty fields.
+func (m *Metadata) Body(model interface{}) map[string]interface{} {
+	value := reflect.Indirect(reflect.ValueOf(model))
+	result := make(map[string]interface{}, len(m.Fields))
+	for _, field := range m.Fields {
+		if len(m.Id) > 0 && field.Name == m.IdName {
+			continue
+		}
+		v, ok := FieldByIndex(value, field.Index)
+		if !ok {
+			continue
+		}
+		result[field.JsonName] = v.Interface()
+	}
+	return result
+}
+
+// documentId returns the id of a model or of the result of its mapping: the "_id" or the json name of the id of the model type in a map, or the id of a struct by the metadata of its own type.
+func documentId(modelType reflect.Type, doc interface{}) string {
+	if m, ok := doc.(map[string]interface{}); ok {
+		if id, ok := m["_id"].(string); ok {
+			return id
+		}
+		id, _ := m[GetMetadata(modelType).JsonIdName].(string)
+		return id
+	}
+	if doc == nil {
+		return ""
+	}
+	return GetMetadata(reflect.TypeOf(doc)).GetId(doc)
+}
+
+// documentBody returns the document of a model or of the result of its mapping, without the id: a map is copied without "_id" and the json name of the id of the model type,
+// and a struct is read by the metadata of its own type, since a mapper may return another type than the model type.
+func documentBody(modelType reflect.Type, doc interface{}) map[string]interface{} {
+	if m, ok := doc.(map[string]interface{}); ok {
+		body := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			body[k] = v
+		}
+		delete(body, "_id")
+		if name := GetMetadata(modelType).JsonIdName; len(name) > 0 {
+			delete(body, name)
+		}
+		return body
+	}
+	if doc == nil {
+		return map[string]interface{}{}
+	}
+	return GetMetadata(reflect.TypeOf(doc)).Body(doc)
+}
+
+func hasFlag(tag string, flag string) bool {
+	params := strings.Split(tag, ",")
+	for _, param := range params[1:] {
+		if strings.TrimSpace(param) == flag {
+			return true
+		}
+	}
+	return false
+}
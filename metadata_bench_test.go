@@ -0,0 +1,210 @@
+package elasticsearch
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type benchUser struct {
+	Id          string    `json:"id" bson:"_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	DateOfBirth time.Time `json:"dateOfBirth"`
+	Status      string    `json:"status"`
+	Country     string    `json:"country" es:"keyword"`
+	Score       float64   `json:"score"`
+	Tags        []string  `json:"tags"`
+	Active      bool      `json:"active"`
+	Version     int64     `json:"version" es:"long,version"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+const benchBulkSize = 50000
+
+// The baseline functions are the functions of the package before the metadata cache, copied without changes but their names.
+
+func baselineFindIdField(modelType reflect.Type) (int, string, string) {
+	return baselineFindBsonField(modelType, "_id")
+}
+func baselineFindBsonField(modelType reflect.Type, bsonName string) (int, string, string) {
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		bsonTag := field.Tag.Get("bson")
+		tags := strings.Split(bsonTag, ",")
+		json := field.Name
+		if tag1, ok1 := field.Tag.Lookup("json"); ok1 {
+			json = strings.Split(tag1, ",")[0]
+		}
+		for _, tag := range tags {
+			if strings.TrimSpace(tag) == bsonName {
+				return i, field.Name, json
+			}
+		}
+	}
+	return -1, "", ""
+}
+
+func baselineFindFieldByIndex(modelType reflect.Type, fieldIndex int) (fieldName, jsonTagName string) {
+	if fieldIndex < modelType.NumField() {
+		field := modelType.Field(fieldIndex)
+		jsonTagName := ""
+		if jsonTag, ok := field.Tag.Lookup("json"); ok {
+			jsonTagName = strings.Split(jsonTag, ",")[0]
+		}
+		return field.Name, jsonTagName
+	}
+	return "", ""
+}
+
+func baselineBuildQueryWithoutIdFromObject(object interface{}) map[string]interface{} {
+	valueOf := reflect.Indirect(reflect.ValueOf(object))
+	idIndex, _, _ := baselineFindIdField(valueOf.Type())
+	result := map[string]interface{}{}
+	for i := 0; i < valueOf.NumField(); i++ {
+		if i != idIndex {
+			_, jsonName := baselineFindFieldByIndex(valueOf.Type(), i)
+			result[jsonName] = valueOf.Field(i).Interface()
+		}
+	}
+	return result
+}
+
+func baselineFindListIdField(modelType reflect.Type, model interface{}) (listIdS []interface{}) {
+	value := reflect.Indirect(reflect.ValueOf(model))
+
+	if value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			sliceValue := value.Index(i).Interface()
+			if idIndex, _, _ := baselineFindIdField(modelType); idIndex >= 0 {
+				modelValue := reflect.Indirect(reflect.ValueOf(sliceValue))
+				idValue := modelValue.Field(idIndex).String()
+				listIdS = append(listIdS, idValue)
+			}
+		}
+	}
+	return
+}
+
+func baselineBuildIndicesResult(listIds, successIds, failIds []interface{}) (successIndices, failureIndices []int) {
+	if len(listIds) > 0 {
+		for _, idValue := range listIds {
+			for index, id := range successIds {
+				if id == idValue {
+					successIndices = append(successIndices, int(index))
+				}
+			}
+			for index, id := range failIds {
+				if id == idValue {
+					failureIndices = append(failureIndices, int(index))
+				}
+			}
+		}
+	}
+	return
+}
+
+func newBenchUser(i int) benchUser {
+	now := time.Now()
+	return benchUser{Id: strconv.Itoa(i), Username: "user" + strconv.Itoa(i), Email: "user@example.com", Phone: "0123456789", DateOfBirth: now, Status: "A", Country: "VN", Score: 1.5, Tags: []string{"a", "b"}, Active: true, Version: 1, CreatedAt: now}
+}
+
+func newBenchUsers(n int) []benchUser {
+	users := make([]benchUser, n)
+	for i := range users {
+		users[i] = newBenchUser(i)
+	}
+	return users
+}
+
+// BenchmarkInsertOne measures the preparation of a single write, the id and the document of the model, as in InsertOne.
+func BenchmarkInsertOne(b *testing.B) {
+	user := newBenchUser(1)
+	modelType := reflect.TypeOf(user)
+	b.Run("baseline", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if idIndex, _, _ := baselineFindIdField(modelType); idIndex >= 0 {
+				modelValue := reflect.Indirect(reflect.ValueOf(user))
+				_ = modelValue.Field(idIndex).String()
+				baselineBuildQueryWithoutIdFromObject(user)
+			}
+		}
+	})
+	b.Run("metadata", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if meta := GetMetadata(modelType); len(meta.Id) > 0 {
+				_ = meta.GetId(user)
+				meta.Body(user)
+			}
+		}
+	})
+}
+
+// BenchmarkInsertMany measures the preparation of the items of a bulk write of 50k models, as in InsertMany: the list of the ids, then the id and the document of each model.
+func BenchmarkInsertMany(b *testing.B) {
+	users := newBenchUsers(benchBulkSize)
+	modelType := reflect.TypeOf(users[0])
+	b.Run("baseline", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			value := reflect.ValueOf(users)
+			baselineFindListIdField(modelType, users)
+			for j := 0; j < value.Len(); j++ {
+				sliceValue := value.Index(j).Interface()
+				if idIndex, _, _ := baselineFindIdField(modelType); idIndex >= 0 {
+					modelValue := reflect.Indirect(reflect.ValueOf(sliceValue))
+					if modelValue.Field(idIndex).String() != "" {
+						baselineBuildQueryWithoutIdFromObject(sliceValue)
+					}
+				}
+			}
+		}
+	})
+	b.Run("metadata", func(b *testing.B) {
+		b.ReportAllocs()
+		ctx := context.Background()
+		for i := 0; i < b.N; i++ {
+			ids := FindListIdField(modelType, users)
+			if _, err := encryptBodies(ctx, modelType, ids, reflect.ValueOf(users)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkBuildIndicesResult measures the matching of the ids of a bulk result to their positions in the results, on 5k models since the baseline is quadratic; both return the same positions.
+func BenchmarkBuildIndicesResult(b *testing.B) {
+	users := newBenchUsers(5000)
+	listIds := FindListIdField(reflect.TypeOf(users[0]), users)
+	var successIds, failIds []interface{}
+	for i := len(listIds) - 1; i >= 0; i-- {
+		if i%10 == 0 {
+			failIds = append(failIds, listIds[i])
+		} else {
+			successIds = append(successIds, listIds[i])
+		}
+	}
+	expectedSuccess, expectedFailure := baselineBuildIndicesResult(listIds, successIds, failIds)
+	if success, failure := BuildIndicesResult(listIds, successIds, failIds); !reflect.DeepEqual(success, expectedSuccess) || !reflect.DeepEqual(failure, expectedFailure) {
+		b.Fatal("BuildIndicesResult does not return the positions of the baseline")
+	}
+	b.Run("baseline", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			baselineBuildIndicesResult(listIds, successIds, failIds)
+		}
+	})
+	b.Run("metadata", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			BuildIndicesResult(listIds, successIds, failIds)
+		}
+	})
+}
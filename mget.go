@@ -0,0 +1,151 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"reflect"
+)
+
+// FindByIds loads the documents by the multi get API. It returns a pointer to a slice of the models, in the order of the ids, and the ids which are not found.
+// The optional parameter is the list of fields of _source to load.
+func FindByIds(ctx context.Context, es *elasticsearch.Client, indexName string, ids []string, modelType reflect.Type, options ...[]string) (interface{}, []string, error) {
+	modelsType := reflect.SliceOf(modelType)
+	result := reflect.New(modelsType).Interface()
+	missing, err := FindByIdsAndDecode(ctx, es, indexName, ids, result, options...)
+	if err != nil {
+		return nil, missing, err
+	}
+	return result, missing, nil
+}
+
+// FindByIdsAndDecode decodes the documents into result, which must be a pointer to a slice, and returns the ids which are not found.
+func FindByIdsAndDecode(ctx context.Context, es *elasticsearch.Client, indexName string, ids []string, result interface{}, options ...[]string) ([]string, error) {
+	sources, err := MultiGet(ctx, es, indexName, ids, options...)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := DecodeSources(ctx, ids, sources, result)
+	if err != nil {
+		return missing, err
+	}
+	return missing, decryptModels(ctx, result)
+}
+
+// MultiGet returns the raw _source of the documents found, by id.
+func MultiGet(ctx context.Context, es *elasticsearch.Client, indexName string, ids []string, options ...[]string) (map[string]json.RawMessage, error) {
+	sources := make(map[string]json.RawMessage)
+	if len(ids) == 0 {
+		return sources, nil
+	}
+	req := esapi.MgetRequest{
+		Index: indexName,
+		Body:  NewReader(ctx, map[string]interface{}{"ids": ids}),
+	}
+	if len(options) > 0 && len(options[0]) > 0 {
+		req.SourceIncludes = options[0]
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return sources, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return sources, errors.New("response error")
+	}
+	var r struct {
+		Docs []struct {
+			Id     string          `json:"_id"`
+			Found  bool            `json:"found"`
+			Source json.RawMessage `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return sources, err
+	}
+	for _, doc := range r.Docs {
+		if doc.Found {
+			sources[doc.Id] = doc.Source
+		}
+	}
+	return sources, nil
+}
+
+// SearchByIds returns the raw _source of the documents found, by id, with an ids query. Unlike MultiGet, it works on an alias or a wildcard targeting several indices.
+func SearchByIds(ctx context.Context, es *elasticsearch.Client, indexName string, ids []string, options ...[]string) (map[string]json.RawMessage, error) {
+	if len(ids) == 0 {
+		return make(map[string]json.RawMessage), nil
+	}
+	return searchSources(ctx, es, indexName, map[string]interface{}{"ids": map[string]interface{}{"values": ids}}, len(ids), options...)
+}
+
+// searchSources returns the sources of the first documents matching the query clause, by id.
+func searchSources(ctx context.Context, es *elasticsearch.Client, indexName string, query map[string]interface{}, size int, options ...[]string) (map[string]json.RawMessage, error) {
+	sources := make(map[string]json.RawMessage)
+	req := esapi.SearchRequest{
+		Index: pathIndices(indexName),
+		Body:  NewReader(ctx, map[string]interface{}{"query": query}),
+		Size:  &size,
+	}
+	if len(options) > 0 && len(options[0]) > 0 {
+		req.SourceIncludes = options[0]
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return sources, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return sources, errors.New("response error")
+	}
+	var r struct {
+		Hits struct {
+			Hits []struct {
+				Id     string          `json:"_id"`
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return sources, err
+	}
+	for _, hit := range r.Hits.Hits {
+		sources[hit.Id] = hit.Source
+	}
+	return sources, nil
+}
+
+// DecodeSources appends the documents to result, a pointer to a slice, in the order of the ids, with the codec of the context. The id field of the models is set from the document id.
+func DecodeSources(ctx context.Context, ids []string, sources map[string]json.RawMessage, result interface{}) ([]string, error) {
+	codec := CodecFromContext(ctx)
+	var missing []string
+	slice := reflect.Indirect(reflect.ValueOf(result))
+	if slice.Kind() != reflect.Slice {
+		return missing, errors.New("result must be a pointer to a slice")
+	}
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	modelType := elemType
+	if isPtr {
+		modelType = elemType.Elem()
+	}
+	for _, id := range ids {
+		source, ok := sources[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		model := reflect.New(modelType)
+		if err := decodeSource(codec, id, source, nil, model.Interface()); err != nil {
+			return missing, err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, model))
+		} else {
+			slice.Set(reflect.Append(slice, model.Elem()))
+		}
+	}
+	return missing, nil
+}
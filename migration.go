@@ -0,0 +1,437 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const migrationLockId = "migration-lock"
+
+type MigrationStep struct {
+	Description string
+	Execute     func(ctx context.Context, es *elasticsearch.Client) error
+}
+
+type Migration struct {
+	Version string
+	Name    string
+	Steps   []MigrationStep
+}
+
+type AppliedMigration struct {
+	Version   string    `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+	Duration  int64     `json:"duration"`
+}
+
+type Migrator struct {
+	client      *elasticsearch.Client
+	indexName   string
+	migrations  []Migration
+	owner       string
+	LockTimeout time.Duration
+	Log         func(format string, args ...interface{})
+}
+
+// NewMigrator creates a runner for the migrations, in the given order. Applied migrations are recorded in the index "migrations", unless another index name is passed in options.
+func NewMigrator(client *elasticsearch.Client, migrations []Migration, options ...string) *Migrator {
+	var indexName string
+	if len(options) > 0 && len(options[0]) > 0 {
+		indexName = options[0]
+	} else {
+		indexName = "migrations"
+	}
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	return &Migrator{client: client, indexName: indexName, migrations: migrations, owner: owner, LockTimeout: 15 * time.Minute, Log: log.Printf}
+}
+
+func NewMigratorWithConfig(config Config, migrations []Migration, options ...string) (*Migrator, error) {
+	client, err := Connect(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewMigrator(client, migrations, options...), nil
+}
+
+func (m *Migrator) Applied(ctx context.Context) (map[string]AppliedMigration, error) {
+	applied := make(map[string]AppliedMigration)
+	exist, err := IndexExists(ctx, m.client, m.indexName)
+	if err != nil || !exist {
+		return applied, err
+	}
+	size := 10000
+	req := esapi.SearchRequest{
+		Index: []string{m.indexName},
+		Body:  NewReader(ctx, map[string]interface{}{"query": map[string]interface{}{"exists": map[string]interface{}{"field": "version"}}}),
+		Size:  &size,
+	}
+	res, err := req.Do(ctx, m.client)
+	if err != nil {
+		return applied, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return applied, errors.New("response error")
+	}
+	var r struct {
+		Hits struct {
+			Hits []struct {
+				Source AppliedMigration `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return applied, err
+	}
+	for _, hit := range r.Hits.Hits {
+		applied[hit.Source.Version] = hit.Source
+	}
+	return applied, nil
+}
+
+// Pending returns the migrations which have not been applied yet, in order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// DryRun returns the description of every step that Migrate would execute, without executing them.
+func (m *Migrator) DryRun(ctx context.Context) ([]string, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, migration := range pending {
+		lines = append(lines, fmt.Sprintf("%s %s", migration.Version, migration.Name))
+		for i, step := range migration.Steps {
+			lines = append(lines, fmt.Sprintf("  %d. %s", i+1, step.Description))
+		}
+	}
+	if m.Log != nil {
+		for _, line := range lines {
+			m.Log("[dry-run] %s", line)
+		}
+	}
+	return lines, nil
+}
+
+// Migrate applies the pending migrations in order and returns the versions which have been applied.
+// Only one instance can run migrations at a time; the others get an error while the lock is held. The lock is renewed while the migrations run, and expires after LockTimeout if the instance dies.
+func (m *Migrator) Migrate(ctx context.Context) ([]string, error) {
+	var versions []string
+	if err := m.validate(); err != nil {
+		return versions, err
+	}
+	// the lock is renewed every third of LockTimeout; a lock without timeout would be expired when it is written
+	if m.LockTimeout/3 <= 0 {
+		return versions, fmt.Errorf("invalid lock timeout %s", m.LockTimeout)
+	}
+	if err := m.ensureIndex(ctx); err != nil {
+		return versions, err
+	}
+	lock, err := m.lock(ctx)
+	if err != nil {
+		return versions, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	renewing := make(chan struct{})
+	go func() {
+		defer close(renewing)
+		m.keepLock(ctx, lock, cancel)
+	}()
+	defer func() {
+		cancel()
+		<-renewing
+		m.unlock(lock)
+	}()
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return versions, err
+	}
+	for _, migration := range pending {
+		start := time.Now()
+		for i, step := range migration.Steps {
+			if m.Log != nil {
+				m.Log("[%s] %d. %s", migration.Version, i+1, step.Description)
+			}
+			if err := step.Execute(ctx, m.client); err != nil {
+				return versions, fmt.Errorf("migration %s failed at step %d (%s): %w", migration.Version, i+1, step.Description, err)
+			}
+		}
+		record := AppliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now(), Duration: time.Since(start).Milliseconds()}
+		if _, err := UpsertOne(ctx, m.client, m.indexName, migration.Version, record); err != nil {
+			return versions, err
+		}
+		versions = append(versions, migration.Version)
+	}
+	return versions, nil
+}
+
+func (m *Migrator) validate() error {
+	versions := make(map[string]bool)
+	for _, migration := range m.migrations {
+		if len(migration.Version) == 0 || migration.Version == migrationLockId {
+			return fmt.Errorf("invalid migration version '%s'", migration.Version)
+		}
+		if versions[migration.Version] {
+			return fmt.Errorf("duplicate migration version '%s'", migration.Version)
+		}
+		versions[migration.Version] = true
+	}
+	return nil
+}
+
+func (m *Migrator) ensureIndex(ctx context.Context) error {
+	exist, err := IndexExists(ctx, m.client, m.indexName)
+	if err != nil || exist {
+		return err
+	}
+	mapping := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"version":   map[string]interface{}{"type": "keyword"},
+				"name":      map[string]interface{}{"type": "keyword"},
+				"appliedAt": map[string]interface{}{"type": "date"},
+				"duration":  map[string]interface{}{"type": "long"},
+				"owner":     map[string]interface{}{"type": "keyword"},
+				"expiredAt": map[string]interface{}{"type": "date"},
+			},
+		},
+	}
+	_, err = CreateIndex(ctx, m.client, m.indexName, mapping)
+	return err
+}
+
+// migrationLock is the version of the lock document written by this instance, to renew and release the lock only while this instance holds it.
+type migrationLock struct {
+	seqNo       int
+	primaryTerm int
+}
+
+// lock creates the lock document, or takes over an expired lock with a compare-and-swap on its version, so that two instances cannot both take it.
+func (m *Migrator) lock(ctx context.Context) (*migrationLock, error) {
+	lock, err := m.writeLock(ctx, nil)
+	if err != nil || lock != nil {
+		return lock, err
+	}
+	owner, expiredAt, current, err := m.getLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		lock, err = m.writeLock(ctx, nil)
+	} else if expiredAt.Before(time.Now()) {
+		lock, err = m.writeLock(ctx, current)
+	}
+	if err != nil || lock != nil {
+		return lock, err
+	}
+	return nil, fmt.Errorf("migrations are locked by %s", owner)
+}
+
+// getLock returns a nil version if there is no lock document.
+func (m *Migrator) getLock(ctx context.Context) (string, time.Time, *migrationLock, error) {
+	req := esapi.GetRequest{
+		Index:      m.indexName,
+		DocumentID: migrationLockId,
+	}
+	res, err := req.Do(ctx, m.client)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return "", time.Time{}, nil, nil
+	}
+	if res.IsError() {
+		return "", time.Time{}, nil, errors.New("response error")
+	}
+	var r struct {
+		SeqNo       int `json:"_seq_no"`
+		PrimaryTerm int `json:"_primary_term"`
+		Source      struct {
+			Owner     string    `json:"owner"`
+			ExpiredAt time.Time `json:"expiredAt"`
+		} `json:"_source"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return "", time.Time{}, nil, err
+	}
+	return r.Source.Owner, r.Source.ExpiredAt, &migrationLock{seqNo: r.SeqNo, primaryTerm: r.PrimaryTerm}, nil
+}
+
+// writeLock creates the lock document if version is nil, or overwrites it if it is still at version. It returns nil if another instance wrote the lock first.
+func (m *Migrator) writeLock(ctx context.Context, version *migrationLock) (*migrationLock, error) {
+	body := map[string]interface{}{
+		"owner":     m.owner,
+		"expiredAt": time.Now().Add(m.LockTimeout),
+	}
+	req := esapi.IndexRequest{
+		Index:      m.indexName,
+		DocumentID: migrationLockId,
+		Body:       NewReader(ctx, body),
+		Refresh:    "true",
+	}
+	if version == nil {
+		req.OpType = "create"
+	} else {
+		seqNo, primaryTerm := version.seqNo, version.primaryTerm
+		req.IfSeqNo = &seqNo
+		req.IfPrimaryTerm = &primaryTerm
+	}
+	res, err := req.Do(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusConflict {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		SeqNo       int `json:"_seq_no"`
+		PrimaryTerm int `json:"_primary_term"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	return &migrationLock{seqNo: r.SeqNo, primaryTerm: r.PrimaryTerm}, nil
+}
+
+// keepLock renews the lock every third of LockTimeout until ctx is done. If the lock cannot be renewed, the migration is cancelled.
+// A renewal does not use ctx, so that the end of the migration does not interrupt it, but it times out after a third of LockTimeout, before the lock can expire.
+// After a timeout, the migration is cancelled; if the renewal was written anyway, its version is unknown, so unlock fails and the lock expires after LockTimeout.
+func (m *Migrator) keepLock(ctx context.Context, lock *migrationLock, cancel context.CancelFunc) {
+	ticker := time.NewTicker(m.LockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c, stop := context.WithTimeout(context.Background(), m.LockTimeout/3)
+			renewed, err := m.writeLock(c, lock)
+			stop()
+			if err == nil && renewed == nil {
+				err = errors.New("the lock has been taken by another instance")
+			}
+			if err != nil {
+				if m.Log != nil {
+					m.Log("cannot renew migration lock: %s", err.Error())
+				}
+				cancel()
+				return
+			}
+			*lock = *renewed
+		}
+	}
+}
+
+// unlock deletes the lock document only if this instance still holds it. It does not use the context of the migration, which may be cancelled.
+func (m *Migrator) unlock(lock *migrationLock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seqNo, primaryTerm := lock.seqNo, lock.primaryTerm
+	req := esapi.DeleteRequest{
+		Index:         m.indexName,
+		DocumentID:    migrationLockId,
+		IfSeqNo:       &seqNo,
+		IfPrimaryTerm: &primaryTerm,
+		Refresh:       "true",
+	}
+	res, err := req.Do(ctx, m.client)
+	if err == nil {
+		defer res.Body.Close()
+		if res.IsError() {
+			err = errors.New(res.Status())
+		}
+	}
+	if err != nil && m.Log != nil {
+		m.Log("cannot release migration lock: %s", err.Error())
+	}
+}
+
+func CreateIndexStep(indexName string, body map[string]interface{}) MigrationStep {
+	return MigrationStep{
+		Description: fmt.Sprintf("create index %s", indexName),
+		Execute: func(ctx context.Context, es *elasticsearch.Client) error {
+			_, err := CreateIndex(ctx, es, indexName, body)
+			return err
+		},
+	}
+}
+
+func PutMappingStep(indexName string, mapping map[string]interface{}) MigrationStep {
+	return MigrationStep{
+		Description: fmt.Sprintf("put mapping on %s", indexName),
+		Execute: func(ctx context.Context, es *elasticsearch.Client) error {
+			_, err := PutMapping(ctx, es, indexName, mapping)
+			return err
+		},
+	}
+}
+
+func AddAliasStep(indexName string, alias string) MigrationStep {
+	return MigrationStep{
+		Description: fmt.Sprintf("add alias %s to %s", alias, indexName),
+		Execute: func(ctx context.Context, es *elasticsearch.Client) error {
+			_, err := PutAlias(ctx, es, indexName, alias)
+			return err
+		},
+	}
+}
+
+func ReindexStep(source string, dest string, options ...map[string]interface{}) MigrationStep {
+	return MigrationStep{
+		Description: fmt.Sprintf("reindex %s into %s", source, dest),
+		Execute: func(ctx context.Context, es *elasticsearch.Client) error {
+			_, err := Reindex(ctx, es, source, dest, options...)
+			return err
+		},
+	}
+}
+
+// BackfillStep runs a painless script on every document of the index matching the query, for example to fill a new field.
+func BackfillStep(indexName string, query map[string]interface{}, script string, params map[string]interface{}) MigrationStep {
+	return MigrationStep{
+		Description: fmt.Sprintf("update by query on %s: %s", indexName, script),
+		Execute: func(ctx context.Context, es *elasticsearch.Client) error {
+			_, err := UpdateByQuery(ctx, es, indexName, query, NewScript(script, params), ByQueryOptions{Conflicts: "proceed", Refresh: true})
+			return err
+		},
+	}
+}
+
+func PutPipelineStep(id string, pipeline Pipeline) MigrationStep {
+	return MigrationStep{
+		Description: fmt.Sprintf("put ingest pipeline %s", id),
+		Execute: func(ctx context.Context, es *elasticsearch.Client) error {
+			_, err := PutPipeline(ctx, es, id, pipeline)
+			return err
+		},
+	}
+}
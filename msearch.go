@@ -0,0 +1,148 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"reflect"
+	"strings"
+)
+
+// MultiSearchRequest is one of the searches of MultiSearch. Results is a pointer to a slice, to decode the hits of this search.
+// If Results is a projection of ModelType, the fields encrypted in ModelType are decrypted.
+type MultiSearchRequest struct {
+	Indices    []string
+	Query      map[string]interface{}
+	Sort       []string
+	From       int
+	Size       int
+	Projection *Projection
+	Results    interface{}
+	ModelType  reflect.Type
+	Map        func(ctx context.Context, model interface{}) (interface{}, error)
+}
+
+type MultiSearchResult struct {
+	Total int64
+	Error error
+}
+
+// NewMultiSearchRequest creates a search of a page of the index, with the same paging as BuildSearchResult.
+func NewMultiSearchRequest(indexName string, query map[string]interface{}, sort []string, pageIndex int64, pageSize int64, results interface{}, options ...func(context.Context, interface{}) (interface{}, error)) MultiSearchRequest {
+	req := MultiSearchRequest{Indices: []string{indexName}, Query: query, Sort: sort, Size: int(pageSize), Results: results}
+	if pageIndex > 1 {
+		req.From = int(pageSize * (pageIndex - 1))
+	}
+	if len(options) > 0 {
+		req.Map = options[0]
+	}
+	return req
+}
+
+// MultiSearch runs the searches in one _msearch request. It returns the total and the error of every search, in the same order; the error is set if only this search fails.
+func MultiSearch(ctx context.Context, es *elasticsearch.Client, requests []MultiSearchRequest) ([]MultiSearchResult, error) {
+	results := make([]MultiSearchResult, len(requests))
+	if len(requests) == 0 {
+		return results, nil
+	}
+	var buf bytes.Buffer
+	codec := CodecFromContext(ctx)
+	for _, r := range requests {
+		header := map[string]interface{}{"index": strings.Join(r.Indices, ",")}
+		if len(r.Indices) > 1 {
+			header["ignore_unavailable"] = true
+		}
+		body, err := buildMultiSearchBody(r)
+		if err != nil {
+			return results, err
+		}
+		for _, line := range []interface{}{header, body} {
+			data, err := codec.Marshal(line)
+			if err != nil {
+				return results, err
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+	}
+	req := esapi.MsearchRequest{
+		Body: &buf,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return results, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return results, errors.New("response error")
+	}
+	var r struct {
+		Responses []struct {
+			SearchResponse
+			Error interface{} `json:"error"`
+		} `json:"responses"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return results, err
+	}
+	for i := range requests {
+		if i >= len(r.Responses) {
+			results[i].Error = errors.New("missing response")
+			continue
+		}
+		response := r.Responses[i]
+		if response.Error != nil {
+			results[i].Error = fmt.Errorf("search error: %v", response.Error)
+			continue
+		}
+		results[i].Total = response.Hits.Total.Value
+		if requests[i].Results != nil {
+			if err := DecodeSearchHits(withEncryptedType(ctx, requests[i].ModelType), response.Hits.Hits, requests[i].Results); err != nil {
+				results[i].Error = err
+				continue
+			}
+			if requests[i].Map != nil {
+				if _, err := MapModels(ctx, requests[i].Results, requests[i].Map); err != nil {
+					results[i].Error = err
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+func buildMultiSearchBody(r MultiSearchRequest) (map[string]interface{}, error) {
+	body := BuildQueryBody(r.Query)
+	body["from"] = r.From
+	body["size"] = r.Size
+	body["track_total_hits"] = true
+	if len(r.Sort) > 0 {
+		sort, err := BuildSortBody(r.Query, r.Sort)
+		if err != nil {
+			return nil, err
+		}
+		body["sort"] = sort
+	}
+	if p := r.Projection; p != nil {
+		source := make(map[string]interface{})
+		if len(p.Includes) > 0 {
+			source["includes"] = p.Includes
+		}
+		if len(p.Excludes) > 0 {
+			source["excludes"] = p.Excludes
+		}
+		if len(source) > 0 {
+			body["_source"] = source
+		}
+		if len(p.DocvalueFields) > 0 {
+			body["docvalue_fields"] = p.DocvalueFields
+		}
+		if len(p.StoredFields) > 0 {
+			body["stored_fields"] = p.StoredFields
+		}
+	}
+	return body, nil
+}
@@ -2,11 +2,9 @@ package elasticsearch
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
-	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"time"
 )
 
@@ -45,7 +43,7 @@ func (p *PasscodeRepository) Save(ctx context.Context, id string, passcode strin
 	req := esapi.UpdateRequest{
 		Index:      p.indexName,
 		DocumentID: id,
-		Body:       esutil.NewJSONReader(pass),
+		Body:       NewReader(ctx, pass),
 		Refresh:    "true",
 	}
 	res, err := req.Do(ctx, p.client)
@@ -58,7 +56,7 @@ func (p *PasscodeRepository) Save(ctx context.Context, id string, passcode strin
 	}
 
 	var temp map[string]interface{}
-	err = json.NewDecoder(res.Body).Decode(&temp)
+	err = decode(ctx, res.Body, &temp)
 	if err != nil {
 		return -1, err
 	}
@@ -0,0 +1,144 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+)
+
+type pipelineKey struct{}
+
+type Pipeline struct {
+	Description string                   `json:"description,omitempty"`
+	Processors  []map[string]interface{} `json:"processors"`
+	OnFailure   []map[string]interface{} `json:"on_failure,omitempty"`
+	Version     int64                    `json:"version,omitempty"`
+}
+
+type SimulatedDocument struct {
+	Source map[string]interface{} `json:"_source,omitempty"`
+	Error  map[string]interface{} `json:"error,omitempty"`
+}
+
+// WithPipeline returns a context which makes the writers use the ingest pipeline for the writes of this call, instead of their own pipeline.
+func WithPipeline(ctx context.Context, pipeline string) context.Context {
+	return context.WithValue(ctx, pipelineKey{}, pipeline)
+}
+
+func PipelineFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	pipeline, _ := ctx.Value(pipelineKey{}).(string)
+	return pipeline
+}
+
+func getPipeline(ctx context.Context, pipeline string) string {
+	if p := PipelineFromContext(ctx); len(p) > 0 {
+		return p
+	}
+	return pipeline
+}
+
+func PutPipeline(ctx context.Context, es *elasticsearch.Client, id string, pipeline Pipeline) (bool, error) {
+	req := esapi.IngestPutPipelineRequest{
+		PipelineID: id,
+		Body:       NewReader(ctx, pipeline),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func GetPipeline(ctx context.Context, es *elasticsearch.Client, id string) (*Pipeline, error) {
+	req := esapi.IngestGetPipelineRequest{
+		PipelineID: id,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r map[string]Pipeline
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	if p, ok := r[id]; ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+
+func DeletePipeline(ctx context.Context, es *elasticsearch.Client, id string) (bool, error) {
+	req := esapi.IngestDeletePipelineRequest{
+		PipelineID: id,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+// SimulatePipeline runs the stored pipeline on the documents without indexing them.
+// If id is empty, the pipeline passed in options is simulated instead of a stored one.
+func SimulatePipeline(ctx context.Context, es *elasticsearch.Client, id string, docs []interface{}, options ...Pipeline) ([]SimulatedDocument, error) {
+	var sources []map[string]interface{}
+	for _, doc := range docs {
+		sources = append(sources, map[string]interface{}{"_source": doc})
+	}
+	body := map[string]interface{}{"docs": sources}
+	if len(id) == 0 && len(options) > 0 {
+		body["pipeline"] = options[0]
+	}
+	req := esapi.IngestSimulateRequest{
+		PipelineID: id,
+		Body:       NewReader(ctx, body),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		Docs []struct {
+			Doc   SimulatedDocument      `json:"doc"`
+			Error map[string]interface{} `json:"error"`
+		} `json:"docs"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	results := make([]SimulatedDocument, len(r.Docs))
+	for i, d := range r.Docs {
+		results[i] = d.Doc
+		if d.Error != nil {
+			results[i].Error = d.Error
+		}
+	}
+	return results, nil
+}
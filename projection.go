@@ -0,0 +1,211 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"reflect"
+	"strings"
+)
+
+// Projection limits the fields returned by a load or a search.
+// Includes and Excludes filter _source; DocvalueFields and StoredFields are returned in the "fields" of a hit, and are decoded like the fields of _source.
+type Projection struct {
+	Includes       []string
+	Excludes       []string
+	DocvalueFields []string
+	StoredFields   []string
+}
+
+// NewProjection builds a projection including the fields of the model, named by their json tags, for example to load a UserSummary from an index of User.
+func NewProjection(modelType reflect.Type) Projection {
+	return Projection{Includes: GetSourceFields(modelType)}
+}
+
+func GetSourceFields(modelType reflect.Type) []string {
+	for modelType.Kind() == reflect.Ptr || modelType.Kind() == reflect.Slice {
+		modelType = modelType.Elem()
+	}
+	var fields []string
+	if modelType.Kind() != reflect.Struct {
+		return fields
+	}
+	idField, _ := findBsonField(modelType, "_id")
+	for _, field := range GetStructFields(modelType) {
+		if field.Name == idField.Name {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("es"); ok && (tag == "-" || strings.HasPrefix(tag, "_")) {
+			continue
+		}
+		fields = append(fields, field.JsonName)
+	}
+	return fields
+}
+
+func ApplyProjection(req *esapi.SearchRequest, p *Projection) {
+	if p == nil {
+		return
+	}
+	req.SourceIncludes = p.Includes
+	req.SourceExcludes = p.Excludes
+	req.DocvalueFields = p.DocvalueFields
+	req.StoredFields = p.StoredFields
+}
+
+func getProjection(options []Projection) *Projection {
+	if len(options) > 0 {
+		return &options[0]
+	}
+	return nil
+}
+
+// HitsToSources returns the _source of every hit, merged with its "fields". A field with a single value is unwrapped from its array.
+func HitsToSources(hits []interface{}) []interface{} {
+	sources := make([]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		sources = append(sources, HitToSource(hit))
+	}
+	return sources
+}
+
+func HitToSource(hit interface{}) map[string]interface{} {
+	source := make(map[string]interface{})
+	h, ok := hit.(map[string]interface{})
+	if !ok {
+		return source
+	}
+	if s, ok := h["_source"].(map[string]interface{}); ok {
+		source = s
+	}
+	if fields, ok := h["fields"].(map[string]interface{}); ok {
+		for k, v := range fields {
+			if values, ok := v.([]interface{}); ok && len(values) == 1 {
+				source[k] = values[0]
+			} else {
+				source[k] = v
+			}
+		}
+	}
+	return source
+}
+
+// DecodeHits decodes the hits into results, a pointer to a slice. Besides the fields of _source, the fields tagged es:"_id", es:"_index", es:"_score", es:"_sort" or es:"_distance" are set from the metadata of the hits;
+// the slice fields receive the inner hits of their name, as described in getInnerHitsFields.
+// For es:"_sort", the first sort value of the hit is used, such as the distance when sorting by distance; es:"_distance" is the distance computed by a geo distance filter of query.BuildBody.
+// The hits are the maps of a response decoded into map[string]interface{}: only their _source is encoded again, with the codec of the context, to be decoded into the models.
+// DecodeSearchHits decodes typed hits without re-encoding them.
+func DecodeHits(ctx context.Context, hits []interface{}, results interface{}) error {
+	list, err := toHits(CodecFromContext(ctx), hits)
+	if err != nil {
+		return err
+	}
+	return DecodeSearchHits(ctx, list, results)
+}
+
+func toHits(codec Codec, hits []interface{}) ([]Hit, error) {
+	list := make([]Hit, 0, len(hits))
+	for _, h := range hits {
+		m, ok := h.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("a hit must be a map")
+		}
+		var hit Hit
+		hit.Index, _ = m["_index"].(string)
+		hit.Id, _ = m["_id"].(string)
+		if score, ok := m["_score"].(float64); ok {
+			hit.Score = &score
+		}
+		if source, ok := m["_source"]; ok {
+			data, err := codec.Marshal(source)
+			if err != nil {
+				return nil, err
+			}
+			hit.Source = data
+		}
+		if fields, ok := m["fields"].(map[string]interface{}); ok {
+			hit.Fields = make(map[string][]interface{}, len(fields))
+			for k, v := range fields {
+				values, _ := v.([]interface{})
+				hit.Fields[k] = values
+			}
+		}
+		hit.Sort, _ = m["sort"].([]interface{})
+		if inner, ok := m["inner_hits"].(map[string]interface{}); ok {
+			hit.InnerHits = make(map[string]InnerHits, len(inner))
+			for name, v := range inner {
+				outer, _ := v.(map[string]interface{})
+				hitsOf, _ := outer["hits"].(map[string]interface{})
+				items, _ := hitsOf["hits"].([]interface{})
+				var innerHits InnerHits
+				var err error
+				if innerHits.Hits.Hits, err = toHits(codec, items); err != nil {
+					return nil, err
+				}
+				hit.InnerHits[name] = innerHits
+			}
+		}
+		list = append(list, hit)
+	}
+	return list, nil
+}
+
+// getInnerHitsFields returns the indexes of the slice fields receiving inner hits, by name of inner hits: the field tagged es:"_inner_hits:name", such as the children of a has_child query, or the field with the json name, such as the nested objects of a nested query.
+func getInnerHitsFields(modelType reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		if field.Type.Kind() != reflect.Slice {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("es"); ok && strings.HasPrefix(tag, innerHitsTag) {
+			fields[strings.TrimPrefix(tag, innerHitsTag)] = i
+		} else if jsonTag, ok := field.Tag.Lookup("json"); ok {
+			if name := strings.Split(jsonTag, ",")[0]; len(name) > 0 && name != "-" {
+				if _, exist := fields[name]; !exist {
+					fields[name] = i
+				}
+			}
+		}
+	}
+	return fields
+}
+
+const innerHitsTag = "_inner_hits:"
+
+func getMetaFields(modelType reflect.Type) map[int]string {
+	metas := make(map[int]string)
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		if tag, ok := modelType.Field(i).Tag.Lookup("es"); ok && strings.HasPrefix(tag, "_") && !strings.HasPrefix(tag, innerHitsTag) {
+			metas[i] = tag
+		}
+	}
+	return metas
+}
+
+func setMetaField(f reflect.Value, value interface{}) {
+	if value == nil || !f.CanSet() {
+		return
+	}
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		f = f.Elem()
+	}
+	switch v := value.(type) {
+	case float64:
+		switch f.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat(v)
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(v))
+		}
+	case string:
+		if f.Kind() == reflect.String {
+			f.SetString(v)
+		}
+	}
+}
@@ -2,15 +2,23 @@ package elasticsearch
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
-	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"strings"
 )
 
 func BuildSearchResult(ctx context.Context, db *elasticsearch.Client, results interface{}, indexName string, query map[string]interface{}, sort []string, pageIndex int64, pageSize int64, initPageSize int64, options...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
+	return BuildSearchResultWithIndices(ctx, db, results, []string{indexName}, query, sort, pageIndex, pageSize, initPageSize, options...)
+}
+
+// BuildSearchResultWithIndices searches on several indices, wildcards or date math expressions; the indices which do not exist are ignored when there are more than one.
+func BuildSearchResultWithIndices(ctx context.Context, db *elasticsearch.Client, results interface{}, indices []string, query map[string]interface{}, sort []string, pageIndex int64, pageSize int64, initPageSize int64, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
+	return BuildSearchResultWithProjection(ctx, db, results, indices, nil, query, sort, pageIndex, pageSize, initPageSize, options...)
+}
+
+// BuildSearchResultWithProjection searches like BuildSearchResultWithIndices, returning only the fields of the projection if it is not nil.
+func BuildSearchResultWithProjection(ctx context.Context, db *elasticsearch.Client, results interface{}, indices []string, projection *Projection, query map[string]interface{}, sort []string, pageIndex int64, pageSize int64, initPageSize int64, options ...func(context.Context, interface{}) (interface{}, error)) (int64, error) {
 	var mp func(context.Context, interface{}) (interface{}, error)
 	if len(options) > 0 {
 		mp = options[0]
@@ -28,13 +36,31 @@ func BuildSearchResult(ctx context.Context, db *elasticsearch.Client, results in
 		from = int(pageSize * (pageIndex - 1))
 		size = int(pageSize)
 	}
+	body := query
+	if hasGeoSort(sort) {
+		body = make(map[string]interface{})
+		for k, v := range query {
+			body[k] = v
+		}
+		sortBody, err := BuildSortBody(query, sort)
+		if err != nil {
+			return 0, err
+		}
+		body["sort"] = sortBody
+		sort = nil
+	}
 	req := esapi.SearchRequest{
-		Index: []string{indexName},
-		Body:  esutil.NewJSONReader(query),
+		Index: pathIndices(indices...),
+		Body:  NewReader(ctx, body),
 		Sort:  sort,
 		From:  &from,
 		Size:  &size,
 	}
+	if len(indices) > 1 {
+		ignoreUnavailable := true
+		req.IgnoreUnavailable = &ignoreUnavailable
+	}
+	ApplyProjection(&req, projection)
 
 	res, err := req.Do(ctx, db)
 	if err != nil {
@@ -45,18 +71,17 @@ func BuildSearchResult(ctx context.Context, db *elasticsearch.Client, results in
 	if res.IsError() {
 		return 0, errors.New("response error")
 	} else {
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		var r SearchResponse
+		if err := decode(ctx, res.Body, &r); err != nil {
 			return 0, err
 		} else {
-			hits := r["hits"].(map[string]interface{})["hits"].([]interface{})
-			count = int64(r["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
-			err := json.NewDecoder(esutil.NewJSONReader(hits)).Decode(results) // err := json.NewDecoder(esutil.NewJSONReader(hits)).Decode(&results)
+			count = r.Hits.Total.Value
+			err := DecodeSearchHits(ctx, r.Hits.Hits, results)
 			if err != nil {
 				return count, err
 			}
 			if mp != nil {
-				MapModels(ctx, results, mp)
+				_, err = MapModels(ctx, results, mp)
 			}
 			return count, err
 		}
@@ -81,3 +106,33 @@ func BuildSort(s string) []string {
 	}
 	return sort
 }
+
+// BuildSortOrders converts a sort such as "-createdAt,name" to the sort of a search request with the orders, such as "createdAt:desc" and "name:asc".
+// The field "_distance" sorts by the distance from the point of the geo distance filter of query.BuildBody, see BuildSortBody.
+func BuildSortOrders(s string) []string {
+	var sort []string
+	if len(s) == 0 {
+		return sort
+	}
+	sorts := strings.Split(s, ",")
+	for i := 0; i < len(sorts); i++ {
+		sortField := strings.TrimSpace(sorts[i])
+		if len(sortField) == 0 {
+			continue
+		}
+		fieldName := sortField
+		direction := "asc"
+		c := sortField[0:1]
+		if c == "-" || c == "+" {
+			fieldName = sortField[1:]
+			if c == "-" {
+				direction = "desc"
+			}
+		}
+		if fieldName == "_distance" {
+			fieldName = geoDistanceSort
+		}
+		sort = append(sort, fieldName+":"+direction)
+	}
+	return sort
+}
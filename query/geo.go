@@ -0,0 +1,89 @@
+package query
+
+// GeoPoint is a latitude and a longitude. GeoDistance, GeoBoundingBox and GeoPolygon are used as pointer fields of a search model; a nil field is not a filter.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// GeoDistance matches the documents within the distance from the point, such as "10km".
+// Unit is the unit of the distance returned per hit in the field tagged es:"_distance" ("m", "km" or "mi"), "km" by default.
+type GeoDistance struct {
+	Point    GeoPoint `json:"point"`
+	Distance string   `json:"distance"`
+	Unit     string   `json:"unit,omitempty"`
+}
+
+type GeoBoundingBox struct {
+	TopLeft     GeoPoint `json:"topLeft"`
+	BottomRight GeoPoint `json:"bottomRight"`
+}
+
+// GeoPolygon matches the documents with a geo_shape or geo_point field intersecting the polygon, or with the relation "within", "disjoint" or "contains".
+type GeoPolygon struct {
+	Points   []GeoPoint `json:"points"`
+	Relation string     `json:"relation,omitempty"`
+}
+
+func buildGeoDistance(columnName string, g GeoDistance) map[string]interface{} {
+	return map[string]interface{}{
+		"geo_distance": map[string]interface{}{
+			"distance": g.Distance,
+			columnName: g.Point,
+		},
+	}
+}
+
+func buildGeoBoundingBox(columnName string, g GeoBoundingBox) map[string]interface{} {
+	return map[string]interface{}{
+		"geo_bounding_box": map[string]interface{}{
+			columnName: map[string]interface{}{
+				"top_left":     g.TopLeft,
+				"bottom_right": g.BottomRight,
+			},
+		},
+	}
+}
+
+func buildGeoPolygon(columnName string, g GeoPolygon) map[string]interface{} {
+	var ring [][]float64
+	for _, p := range g.Points {
+		ring = append(ring, []float64{p.Lon, p.Lat})
+	}
+	if len(g.Points) > 0 && g.Points[0] != g.Points[len(g.Points)-1] {
+		ring = append(ring, []float64{g.Points[0].Lon, g.Points[0].Lat})
+	}
+	relation := g.Relation
+	if len(relation) == 0 {
+		relation = "intersects"
+	}
+	return map[string]interface{}{
+		"geo_shape": map[string]interface{}{
+			columnName: map[string]interface{}{
+				"shape":    map[string]interface{}{"type": "polygon", "coordinates": [][][]float64{ring}},
+				"relation": relation,
+			},
+		},
+	}
+}
+
+// buildDistanceField returns the script field computing the distance of a hit from the point, in the unit.
+func buildDistanceField(columnName string, g GeoDistance) map[string]interface{} {
+	unit := g.Unit
+	var factor float64
+	switch unit {
+	case "m":
+		factor = 1
+	case "mi":
+		factor = 1609.344
+	default:
+		unit = "km"
+		factor = 1000
+	}
+	return map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": "doc[params.field].arcDistance(params.lat, params.lon) / params.factor",
+			"params": map[string]interface{}{"field": columnName, "lat": g.Point.Lat, "lon": g.Point.Lon, "factor": factor, "unit": unit},
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package query
+
+import "reflect"
+
+// HasChild matches the parent documents of a join field having children of the type which match the query.
+// Query is a search model, a search body or a query clause; the children are returned as inner hits named by the type if InnerHits is true.
+// ModelType is the model of the children, whose json names are the names of the fields of a search model; if it is nil, the fields of the search model are used.
+type HasChild struct {
+	Type        string       `json:"type"`
+	Query       interface{}  `json:"query,omitempty"`
+	ModelType   reflect.Type `json:"-"`
+	ScoreMode   string       `json:"scoreMode,omitempty"`
+	MinChildren int          `json:"minChildren,omitempty"`
+	MaxChildren int          `json:"maxChildren,omitempty"`
+	InnerHits   bool         `json:"innerHits,omitempty"`
+}
+
+// HasParent matches the child documents whose parent of the type matches the query. The parent is returned as inner hits named by the type if InnerHits is true.
+// ModelType is the model of the parent, as for HasChild.
+type HasParent struct {
+	ParentType string       `json:"parentType"`
+	Query      interface{}  `json:"query,omitempty"`
+	ModelType  reflect.Type `json:"-"`
+	Score      bool         `json:"score,omitempty"`
+	InnerHits  bool         `json:"innerHits,omitempty"`
+}
+
+func buildHasChild(h HasChild) map[string]interface{} {
+	q := map[string]interface{}{
+		"type":  h.Type,
+		"query": buildClause(h.Query, h.ModelType),
+	}
+	if len(h.ScoreMode) > 0 {
+		q["score_mode"] = h.ScoreMode
+	}
+	if h.MinChildren > 0 {
+		q["min_children"] = h.MinChildren
+	}
+	if h.MaxChildren > 0 {
+		q["max_children"] = h.MaxChildren
+	}
+	if h.InnerHits {
+		q["inner_hits"] = map[string]interface{}{"name": h.Type}
+	}
+	return map[string]interface{}{"has_child": q}
+}
+
+func buildHasParent(h HasParent) map[string]interface{} {
+	q := map[string]interface{}{
+		"parent_type": h.ParentType,
+		"query":       buildClause(h.Query, h.ModelType),
+	}
+	if h.Score {
+		q["score"] = true
+	}
+	if h.InnerHits {
+		q["inner_hits"] = map[string]interface{}{"name": h.ParentType}
+	}
+	return map[string]interface{}{"has_parent": q}
+}
+
+func buildNested(path string, clauses []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path":       path,
+			"query":      map[string]interface{}{"bool": map[string]interface{}{"filter": clauses}},
+			"inner_hits": map[string]interface{}{"name": path},
+		},
+	}
+}
+
+// buildClause returns the query clause of a search model for the model type, of a search body or of a query clause; nil matches all documents.
+func buildClause(query interface{}, modelType reflect.Type) interface{} {
+	var clause interface{}
+	switch q := query.(type) {
+	case nil:
+	case map[string]interface{}:
+		if c, ok := q["query"]; ok {
+			clause = c
+		} else if len(q) > 0 {
+			clause = q
+		}
+	default:
+		if v := reflect.Indirect(reflect.ValueOf(query)); v.Kind() == reflect.Struct {
+			if modelType == nil {
+				modelType = v.Type()
+			}
+			clause = BuildBody(query, modelType)["query"]
+		}
+	}
+	if clause == nil {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	return clause
+}
@@ -18,6 +18,12 @@ func (b *Builder) BuildQuery(sm interface{}) map[string]interface{} {
 	return Build(sm, b.ModelType)
 }
 
+// BuildBody returns the search body of Elasticsearch of the search model, for the result model type of the builder.
+func (b *Builder) BuildBody(sm interface{}) map[string]interface{} {
+	return BuildBody(sm, b.ModelType)
+}
+
+// Build returns the filter of the search model as a map of the operators $gte, $gt, $lte, $lt, $in and $nin by field name; use BuildBody for the search body of Elasticsearch.
 func Build(sm interface{}, resultModelType reflect.Type) map[string]interface{} {
 	query := map[string]interface{}{}
 	if _, ok := sm.(*search.SearchModel); ok {
@@ -133,17 +139,251 @@ func Build(sm interface{}, resultModelType reflect.Type) map[string]interface{}
 	return query
 }
 
+// BuildBody returns the body of a search request, with a bool query filtering by the fields of the search model which are set.
+// The filters are queries of Elasticsearch: range for DateRange, TimeRange and NumberRange, terms for a slice, match for a string, term for another value, and must_not terms for the excluded values of the SearchModel.
+// A field of the search model tagged es:"nested:path" filters the nested objects of the path: the filters on the same path are matched by the same nested object, returned as inner hits named by the path.
+// A GeoDistance field adds the "_distance" script field, the distance of every hit from the point, decoded into the field of the result tagged es:"_distance".
+func BuildBody(sm interface{}, resultModelType reflect.Type) map[string]interface{} {
+	query := map[string]interface{}{}
+	if _, ok := sm.(*search.SearchModel); ok {
+		return query
+	}
+	var filter []interface{}
+	var mustNot []interface{}
+	scriptFields := map[string]interface{}{}
+	var paths []string
+	nested := map[string][]interface{}{}
+	value := reflect.Indirect(reflect.ValueOf(sm))
+	numField := value.NumField()
+	for i := 0; i < numField; i++ {
+		fieldValue := value.Field(i).Interface()
+		n := len(filter)
+		if v, ok := fieldValue.(*search.SearchModel); ok {
+			if v != nil && len(v.Excluding) > 0 {
+				for key, val := range v.Excluding {
+					if len(val) > 0 {
+						mustNot = append(mustNot, map[string]interface{}{"terms": map[string]interface{}{key: val}})
+					}
+				}
+			}
+			continue
+		} else if rangeDate, ok := fieldValue.(search.DateRange); ok {
+			columnName := getColumnName(resultModelType, value.Type().Field(i))
+			if q := buildDateRange(rangeDate); len(q) > 0 {
+				filter = append(filter, map[string]interface{}{"range": map[string]interface{}{columnName: q}})
+			}
+		} else if rangeDate, ok := fieldValue.(*search.DateRange); ok {
+			if rangeDate != nil {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				if q := buildDateRange(*rangeDate); len(q) > 0 {
+					filter = append(filter, map[string]interface{}{"range": map[string]interface{}{columnName: q}})
+				}
+			}
+		} else if rangeTime, ok := fieldValue.(search.TimeRange); ok {
+			columnName := getColumnName(resultModelType, value.Type().Field(i))
+			if q := buildTimeRange(rangeTime); len(q) > 0 {
+				filter = append(filter, map[string]interface{}{"range": map[string]interface{}{columnName: q}})
+			}
+		} else if rangeTime, ok := fieldValue.(*search.TimeRange); ok {
+			if rangeTime != nil {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				if q := buildTimeRange(*rangeTime); len(q) > 0 {
+					filter = append(filter, map[string]interface{}{"range": map[string]interface{}{columnName: q}})
+				}
+			}
+		} else if numberRange, ok := fieldValue.(search.NumberRange); ok {
+			columnName := getColumnName(resultModelType, value.Type().Field(i))
+			if q := buildNumberRange(numberRange); len(q) > 0 {
+				filter = append(filter, map[string]interface{}{"range": map[string]interface{}{columnName: q}})
+			}
+		} else if numberRange, ok := fieldValue.(*search.NumberRange); ok {
+			if numberRange != nil {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				if q := buildNumberRange(*numberRange); len(q) > 0 {
+					filter = append(filter, map[string]interface{}{"range": map[string]interface{}{columnName: q}})
+				}
+			}
+		} else if geo, ok := fieldValue.(*GeoDistance); ok {
+			if geo != nil && len(geo.Distance) > 0 {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				filter = append(filter, buildGeoDistance(columnName, *geo))
+				scriptFields["_distance"] = buildDistanceField(columnName, *geo)
+			}
+		} else if geo, ok := fieldValue.(*GeoBoundingBox); ok {
+			if geo != nil {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				filter = append(filter, buildGeoBoundingBox(columnName, *geo))
+			}
+		} else if geo, ok := fieldValue.(*GeoPolygon); ok {
+			if geo != nil && len(geo.Points) > 2 {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				filter = append(filter, buildGeoPolygon(columnName, *geo))
+			}
+		} else if h, ok := fieldValue.(*HasChild); ok {
+			if h != nil && len(h.Type) > 0 {
+				filter = append(filter, buildHasChild(*h))
+			}
+		} else if h, ok := fieldValue.(*HasParent); ok {
+			if h != nil && len(h.ParentType) > 0 {
+				filter = append(filter, buildHasParent(*h))
+			}
+		} else if value.Field(i).Kind() == reflect.Slice {
+			if value.Field(i).Len() > 0 {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{columnName: fieldValue}})
+			}
+		} else {
+			t := value.Field(i).Kind().String()
+			if t == "bool" || (strings.Contains(t, "int") && !value.Field(i).IsZero()) || (strings.Contains(t, "float") && !value.Field(i).IsZero()) || (t == "string" && value.Field(i).Len() > 0) || (t == "ptr" &&
+				value.Field(i).Pointer() != 0) {
+				columnName := getColumnName(resultModelType, value.Type().Field(i))
+				if len(columnName) > 0 {
+					if t == "string" {
+						filter = append(filter, map[string]interface{}{"match": map[string]interface{}{columnName: fieldValue}})
+					} else {
+						filter = append(filter, map[string]interface{}{"term": map[string]interface{}{columnName: fieldValue}})
+					}
+				}
+			}
+		}
+		if path := getNestedPath(value.Type().Field(i)); len(path) > 0 && len(filter) > n {
+			if _, ok := nested[path]; !ok {
+				paths = append(paths, path)
+			}
+			nested[path] = append(nested[path], filter[n:]...)
+			filter = filter[:n]
+		}
+	}
+	for _, path := range paths {
+		filter = append(filter, buildNested(path, nested[path]))
+	}
+	if len(filter) == 0 && len(mustNot) == 0 {
+		return query
+	}
+	boolQuery := map[string]interface{}{}
+	if len(filter) > 0 {
+		boolQuery["filter"] = filter
+	}
+	if len(mustNot) > 0 {
+		boolQuery["must_not"] = mustNot
+	}
+	query["query"] = map[string]interface{}{"bool": boolQuery}
+	if len(scriptFields) > 0 {
+		query["script_fields"] = scriptFields
+		query["_source"] = true
+	}
+	return query
+}
+
+func buildDateRange(rangeDate search.DateRange) map[string]interface{} {
+	q := map[string]interface{}{}
+	if rangeDate.StartDate != nil {
+		q["gte"] = rangeDate.StartDate
+	}
+	if rangeDate.EndDate != nil {
+		q["lte"] = rangeDate.EndDate.Add(time.Hour * 24)
+	}
+	return q
+}
+
+func buildTimeRange(rangeTime search.TimeRange) map[string]interface{} {
+	q := map[string]interface{}{}
+	if rangeTime.StartTime != nil {
+		q["gte"] = rangeTime.StartTime
+	}
+	if rangeTime.EndTime != nil {
+		q["lt"] = rangeTime.EndTime
+	}
+	return q
+}
+
+func buildNumberRange(numberRange search.NumberRange) map[string]interface{} {
+	q := map[string]interface{}{}
+	if numberRange.Min != nil {
+		q["gte"] = *numberRange.Min
+	} else if numberRange.Lower != nil {
+		q["gt"] = *numberRange.Lower
+	}
+	if numberRange.Max != nil {
+		q["lte"] = *numberRange.Max
+	} else if numberRange.Upper != nil {
+		q["lt"] = *numberRange.Upper
+	}
+	return q
+}
+
+func getNestedPath(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("es"); ok && strings.HasPrefix(tag, "nested:") {
+		return strings.TrimPrefix(tag, "nested:")
+	}
+	return ""
+}
+
+// getColumnName returns the name of the field of the result model matching the field of the search model; for a nested field, it is the path followed by the name of the field in the nested model.
+func getColumnName(resultModelType reflect.Type, field reflect.StructField) string {
+	path := getNestedPath(field)
+	if len(path) == 0 {
+		_, columnName := findFieldByName(resultModelType, field.Name)
+		return columnName
+	}
+	name := field.Name
+	if jsonTag, ok := field.Tag.Lookup("json"); ok && len(strings.Split(jsonTag, ",")[0]) > 0 {
+		name = strings.Split(jsonTag, ",")[0]
+	}
+	if elemType := findNestedType(resultModelType, path); elemType != nil {
+		if index, jsonName := findFieldByName(elemType, field.Name); index >= 0 {
+			name = jsonName
+		}
+	}
+	return path + "." + name
+}
+
+// findNestedType returns the struct type of the objects of the path of the model, such as "items" for a field []Item with the json tag "items".
+func findNestedType(modelType reflect.Type, path string) reflect.Type {
+	t := modelType
+	for _, name := range strings.Split(path, ".") {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if jsonTag, ok := field.Tag.Lookup("json"); ok && strings.Split(jsonTag, ",")[0] == name {
+				t = field.Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// findFieldByName finds the promoted fields of the embedded structs too, with the index of their embedded struct.
 func findFieldByName(modelType reflect.Type, fieldName string) (index int, jsonTagName string) {
-	numField := modelType.NumField()
-	for index := 0; index < numField; index++ {
-		field := modelType.Field(index)
-		if field.Name == fieldName {
-			jsonTagName := fieldName
-			if jsonTag, ok := field.Tag.Lookup("json"); ok {
-				jsonTagName = strings.Split(jsonTag, ",")[0]
-			}
-			return index, jsonTagName
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() != reflect.Struct {
+		return -1, fieldName
+	}
+	if field, ok := modelType.FieldByName(fieldName); ok {
+		jsonTagName := fieldName
+		if jsonTag, ok := field.Tag.Lookup("json"); ok && len(strings.Split(jsonTag, ",")[0]) > 0 {
+			jsonTagName = strings.Split(jsonTag, ",")[0]
 		}
+		return field.Index[0], jsonTagName
 	}
 	return -1, fieldName
 }
@@ -0,0 +1,212 @@
+package query
+
+import (
+	"github.com/core-go/search"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Id        string    `json:"id"`
+	Username  string    `json:"username"`
+	Status    []string  `json:"status"`
+	Age       int       `json:"age"`
+	Active    bool      `json:"active"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type userFilter struct {
+	*search.SearchModel
+	Username  string              `json:"username"`
+	Status    []string            `json:"status"`
+	Age       int                 `json:"age"`
+	Active    bool                `json:"active"`
+	CreatedAt *search.DateRange   `json:"createdAt"`
+	Score     *search.NumberRange `json:"score"`
+}
+
+type dateFilter struct {
+	CreatedAt search.DateRange `json:"createdAt"`
+}
+
+type timeFilter struct {
+	CreatedAt search.TimeRange `json:"createdAt"`
+}
+
+type timePointerFilter struct {
+	CreatedAt *search.TimeRange `json:"createdAt"`
+}
+
+type numberFilter struct {
+	Score search.NumberRange `json:"score"`
+}
+
+var userType = reflect.TypeOf(user{})
+
+func filterOf(body map[string]interface{}) []interface{} {
+	q, _ := body["query"].(map[string]interface{})
+	b, _ := q["bool"].(map[string]interface{})
+	filter, _ := b["filter"].([]interface{})
+	return filter
+}
+
+func TestBuild(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	nextDay := end.Add(24 * time.Hour)
+	min, upper := 1.5, 9.0
+	tests := []struct {
+		name     string
+		sm       interface{}
+		expected map[string]interface{}
+	}{
+		{"date range", &dateFilter{CreatedAt: search.DateRange{StartDate: &start, EndDate: &end}}, map[string]interface{}{
+			"createdAt": map[string]interface{}{"$gte": &start, "$lte": &nextDay},
+		}},
+		{"time range", &timeFilter{CreatedAt: search.TimeRange{StartTime: &start, EndTime: &end}}, map[string]interface{}{
+			"createdAt": map[string]interface{}{"$gte": &start, "$lt": &end},
+		}},
+		{"number range", &numberFilter{Score: search.NumberRange{Min: &min, Upper: &upper}}, map[string]interface{}{
+			"score": map[string]interface{}{"$gte": min, "$lt": upper},
+		}},
+		{"slice, string and excluding", &userFilter{SearchModel: &search.SearchModel{Excluding: map[string][]string{"id": {"1"}}}, Username: "peter", Status: []string{"A"}}, map[string]interface{}{
+			"id":       map[string]interface{}{"$nin": []string{"1"}},
+			"username": "peter",
+			"status":   map[string]interface{}{"$in": []string{"A"}},
+			"active":   false,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := Build(tt.sm, userType)
+			if len(query) != len(tt.expected) {
+				t.Fatalf("query %v, expected %v", query, tt.expected)
+			}
+			for k, v := range tt.expected {
+				if got, ok := query[k]; !ok || !reflect.DeepEqual(got, v) {
+					t.Errorf("%s: %v, expected %v", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildBody(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	nextDay := end.Add(24 * time.Hour)
+	min, max, lower, upper := 1.5, 8.0, 2.0, 9.0
+	tests := []struct {
+		name    string
+		sm      interface{}
+		filter  []interface{}
+		mustNot []interface{}
+	}{
+		{"date range", &dateFilter{CreatedAt: search.DateRange{StartDate: &start, EndDate: &end}}, []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"createdAt": map[string]interface{}{"gte": &start, "lte": nextDay}}},
+		}, nil},
+		{"date range without end", &dateFilter{CreatedAt: search.DateRange{StartDate: &start}}, []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"createdAt": map[string]interface{}{"gte": &start}}},
+		}, nil},
+		{"empty date range", &dateFilter{}, nil, nil},
+		{"date range pointer", &userFilter{CreatedAt: &search.DateRange{EndDate: &end}}, []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"active": false}},
+			map[string]interface{}{"range": map[string]interface{}{"createdAt": map[string]interface{}{"lte": nextDay}}},
+		}, nil},
+		{"time range", &timeFilter{CreatedAt: search.TimeRange{StartTime: &start, EndTime: &end}}, []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"createdAt": map[string]interface{}{"gte": &start, "lt": &end}}},
+		}, nil},
+		{"time range pointer", &timePointerFilter{CreatedAt: &search.TimeRange{StartTime: &start}}, []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"createdAt": map[string]interface{}{"gte": &start}}},
+		}, nil},
+		{"nil time range pointer", &timePointerFilter{}, nil, nil},
+		{"number range min and max", &numberFilter{Score: search.NumberRange{Min: &min, Max: &max}}, []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"score": map[string]interface{}{"gte": min, "lte": max}}},
+		}, nil},
+		{"number range lower and upper", &numberFilter{Score: search.NumberRange{Lower: &lower, Upper: &upper}}, []interface{}{
+			map[string]interface{}{"range": map[string]interface{}{"score": map[string]interface{}{"gt": lower, "lt": upper}}},
+		}, nil},
+		{"number range pointer", &userFilter{Active: true, Score: &search.NumberRange{Min: &min}}, []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"active": true}},
+			map[string]interface{}{"range": map[string]interface{}{"score": map[string]interface{}{"gte": min}}},
+		}, nil},
+		{"slice", &userFilter{Status: []string{"A", "I"}}, []interface{}{
+			map[string]interface{}{"terms": map[string]interface{}{"status": []string{"A", "I"}}},
+			map[string]interface{}{"term": map[string]interface{}{"active": false}},
+		}, nil},
+		{"string and int", &userFilter{Username: "peter", Age: 30}, []interface{}{
+			map[string]interface{}{"match": map[string]interface{}{"username": "peter"}},
+			map[string]interface{}{"term": map[string]interface{}{"age": 30}},
+			map[string]interface{}{"term": map[string]interface{}{"active": false}},
+		}, nil},
+		{"excluding", &userFilter{SearchModel: &search.SearchModel{Excluding: map[string][]string{"id": {"1", "2"}}}}, []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"active": false}},
+		}, []interface{}{
+			map[string]interface{}{"terms": map[string]interface{}{"id": []string{"1", "2"}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := BuildBody(tt.sm, userType)
+			if tt.filter == nil && tt.mustNot == nil {
+				if len(body) != 0 {
+					t.Fatalf("body %v, expected an empty body", body)
+				}
+				return
+			}
+			if filter := filterOf(body); !reflect.DeepEqual(filter, tt.filter) {
+				t.Errorf("filter %v, expected %v", filter, tt.filter)
+			}
+			b := body["query"].(map[string]interface{})["bool"].(map[string]interface{})
+			if mustNot, _ := b["must_not"].([]interface{}); !reflect.DeepEqual(mustNot, tt.mustNot) {
+				t.Errorf("must_not %v, expected %v", mustNot, tt.mustNot)
+			}
+		})
+	}
+}
+
+func TestBuildBodySearchModel(t *testing.T) {
+	if body := BuildBody(&search.SearchModel{}, userType); len(body) != 0 {
+		t.Errorf("body %v, expected an empty body", body)
+	}
+}
+
+type comment struct {
+	Author string `json:"author_name"`
+}
+
+type commentFilter struct {
+	Author string
+}
+
+type postFilter struct {
+	Comments *HasChild
+}
+
+func TestBuildBodyHasChild(t *testing.T) {
+	tests := []struct {
+		name      string
+		modelType reflect.Type
+		field     string
+	}{
+		{"fields of the search model", nil, "Author"},
+		{"json names of the child model", reflect.TypeOf(comment{}), "author_name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := &postFilter{Comments: &HasChild{Type: "comment", Query: &commentFilter{Author: "peter"}, ModelType: tt.modelType}}
+			filter := filterOf(BuildBody(sm, reflect.TypeOf(postFilter{})))
+			expected := []interface{}{map[string]interface{}{"has_child": map[string]interface{}{
+				"type": "comment",
+				"query": map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{
+					map[string]interface{}{"match": map[string]interface{}{tt.field: "peter"}},
+				}}},
+			}}}
+			if !reflect.DeepEqual(filter, expected) {
+				t.Errorf("filter %v, expected %v", filter, expected)
+			}
+		})
+	}
+}
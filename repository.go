@@ -0,0 +1,276 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"reflect"
+)
+
+// Repository is a typed wrapper of Writer: the id field is the field with the bson tag "_id", and the version field is passed by name in options, as for NewWriter, or tagged es:",version".
+// The version is an optimistic lock: Update and Save write the document only if its stored version is the version of the model, else they return ErrVersionConflict.
+type Repository[T any, K comparable] struct {
+	writer      *Writer
+	modelType   reflect.Type
+	versionJson string
+}
+
+func NewRepository[T any, K comparable](client *elasticsearch.Client, indexName string, options ...string) *Repository[T, K] {
+	return NewRepositoryWithMapper[T, K](client, indexName, nil, options...)
+}
+
+func NewRepositoryWithMapper[T any, K comparable](client *elasticsearch.Client, indexName string, mapper Mapper, options ...string) *Repository[T, K] {
+	var t T
+	modelType := reflect.TypeOf(t)
+	writer := NewWriterWithMapper(client, indexName, modelType, mapper, options...)
+	repository := &Repository[T, K]{writer: writer, modelType: modelType}
+	if len(writer.versionIndex) > 0 {
+		for _, field := range GetStructFields(modelType) {
+			if equalIndex(field.Index, writer.versionIndex) {
+				repository.versionJson = field.JsonName
+			}
+		}
+	}
+	return repository
+}
+
+// Writer returns the untyped writer, to set its options such as Pipeline or GetIndex.
+func (r *Repository[T, K]) Writer() *Writer {
+	return r.writer
+}
+
+// Load returns nil if the document does not exist.
+func (r *Repository[T, K]) Load(ctx context.Context, id K) (*T, error) {
+	models, _, err := r.LoadMany(ctx, []K{id})
+	if err != nil || len(models) == 0 {
+		return nil, err
+	}
+	return &models[0], nil
+}
+
+// LoadMany returns the models in the order of the ids, and the ids which are not found.
+func (r *Repository[T, K]) LoadMany(ctx context.Context, ids []K) ([]T, []K, error) {
+	sids := make([]string, len(ids))
+	keys := make(map[string]K, len(ids))
+	for i, id := range ids {
+		sids[i] = toId(id)
+		keys[sids[i]] = id
+	}
+	var models []T
+	missing, err := r.writer.LoadManyAndDecode(ctx, sids, &models)
+	var missingIds []K
+	for _, sid := range missing {
+		missingIds = append(missingIds, keys[sid])
+	}
+	return models, missingIds, err
+}
+
+func (r *Repository[T, K]) Exist(ctx context.Context, id K) (bool, error) {
+	return r.writer.Exist(ctx, toId(id))
+}
+
+func (r *Repository[T, K]) All(ctx context.Context) ([]T, error) {
+	ctx = r.writer.context(ctx)
+	var models []T
+	query := BuildQueryMap(r.writer.indexName, nil)
+	if r.writer.SoftDelete.excludes(ctx) {
+		query = r.writer.SoftDelete.Exclude(query)
+	}
+	_, err := FindAndDecode(ctx, r.writer.client, []string{r.writer.indexName}, query, &models)
+	if err != nil {
+		return nil, err
+	}
+	if r.writer.Map != nil {
+		_, err = MapModels(ctx, &models, r.writer.Map)
+	}
+	return models, err
+}
+
+// Insert sets the version of the model to 1. The model is changed only if the write succeeds.
+func (r *Repository[T, K]) Insert(ctx context.Context, model *T) (int64, error) {
+	item := *model
+	r.setVersion(&item, false)
+	res, err := r.writer.Insert(ctx, &item)
+	if err == nil && res > 0 {
+		*model = item
+	}
+	return res, err
+}
+
+// Update increments the version of the model; the document is updated only if its stored version is the version of the model, else ErrVersionConflict is returned. The model is changed only if the write succeeds.
+func (r *Repository[T, K]) Update(ctx context.Context, model *T) (int64, error) {
+	item := *model
+	ctx = r.versioned(ctx, &item)
+	r.setVersion(&item, true)
+	res, err := r.writer.Update(ctx, &item)
+	if err == nil && res > 0 {
+		*model = item
+	}
+	return res, err
+}
+
+func (r *Repository[T, K]) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	return r.writer.Patch(ctx, model)
+}
+
+// Save increments the version of the model; an existing document is replaced only if its stored version is the version of the model, else ErrVersionConflict is returned. The model is changed only if the write succeeds.
+func (r *Repository[T, K]) Save(ctx context.Context, model *T) (int64, error) {
+	item := *model
+	ctx = r.versioned(ctx, &item)
+	r.setVersion(&item, true)
+	res, err := r.writer.Save(ctx, &item)
+	if err == nil && res > 0 {
+		*model = item
+	}
+	return res, err
+}
+
+func (r *Repository[T, K]) Delete(ctx context.Context, id K) (int64, error) {
+	return r.writer.Delete(ctx, toId(id))
+}
+
+func (r *Repository[T, K]) Restore(ctx context.Context, id K) (int64, error) {
+	return r.writer.Restore(ctx, toId(id))
+}
+
+// Search returns a page of the models matching the query, and the total number of matching documents. The equality clauses on the encrypted fields are translated by EncryptQuery, and the soft-deleted documents are excluded unless the context is WithDeleted. The sort is converted by BuildSortOrders, such as "-createdAt,_distance".
+func (r *Repository[T, K]) Search(ctx context.Context, query map[string]interface{}, sort string, pageIndex int64, pageSize int64, options ...int64) ([]T, int64, error) {
+	ctx = r.writer.context(ctx)
+	query, err := EncryptQuery(ctx, r.modelType, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.writer.SoftDelete.excludes(ctx) {
+		query = r.writer.SoftDelete.Exclude(query)
+	}
+	var initPageSize int64
+	if len(options) > 0 && options[0] > 0 {
+		initPageSize = options[0]
+	}
+	var models []T
+	total, err := BuildSearchResult(ctx, r.writer.client, &models, r.writer.indexName, query, BuildSortOrders(sort), pageIndex, pageSize, initPageSize, r.writer.Map)
+	return models, total, err
+}
+
+// Count returns the number of documents matching the query, without the soft-deleted documents unless the context is WithDeleted.
+func (r *Repository[T, K]) Count(ctx context.Context, query map[string]interface{}) (int64, error) {
+	ctx = r.writer.context(ctx)
+	query, err := EncryptQuery(ctx, r.modelType, query)
+	if err != nil {
+		return 0, err
+	}
+	if r.writer.SoftDelete.excludes(ctx) {
+		query = r.writer.SoftDelete.Exclude(query)
+	}
+	return Count(ctx, r.writer.client, r.writer.indexName, query)
+}
+
+// InsertMany returns the indices of the models which are inserted and the indices of the models which fail. The version and the audit fields are set only in the models which are inserted.
+func (r *Repository[T, K]) InsertMany(ctx context.Context, models []T) ([]int, []int, error) {
+	ctx = r.writer.context(ctx)
+	items := make([]T, len(models))
+	for i := range models {
+		items[i] = models[i]
+		SetAuditFields(ctx, &items[i], true)
+		r.setVersion(&items[i], false)
+	}
+	var docs interface{} = items
+	if r.writer.Mapper != nil {
+		mapped, err := MapToDbModels(ctx, items, r.writer.Mapper.ModelToDb)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = mapped
+	}
+	success, failure, err := writeMany(ctx, r.writer.client, r.writer.indexName, r.modelType, "create", items, docs, getPipeline(ctx, r.writer.Pipeline), nil)
+	r.copyWritten(models, items, success)
+	return success, failure, err
+}
+
+// UpsertMany returns the indices of the models which are written and the indices of the models which fail. The version and the audit fields are set only in the models which are written.
+// The versions are incremented but not checked against the stored versions; use Save for a write conditional on the version.
+func (r *Repository[T, K]) UpsertMany(ctx context.Context, models []T) ([]int, []int, error) {
+	ctx = r.writer.context(ctx)
+	items := make([]T, len(models))
+	for i := range models {
+		items[i] = models[i]
+		SetAuditFields(ctx, &items[i], true)
+		r.setVersion(&items[i], true)
+	}
+	var docs interface{} = items
+	if r.writer.Mapper != nil {
+		mapped, err := MapToDbModels(ctx, items, r.writer.Mapper.ModelToDb)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = mapped
+	}
+	success, failure, err := writeMany(ctx, r.writer.client, r.writer.indexName, r.modelType, "index", items, docs, getPipeline(ctx, r.writer.Pipeline), nil)
+	r.copyWritten(models, items, success)
+	return success, failure, err
+}
+
+// setVersion sets the version field to 1 for an insert, or increments it for an update. It is called on a copy, not to change the model of the caller if the write fails.
+func (r *Repository[T, K]) setVersion(model *T, increment bool) {
+	if len(r.writer.versionIndex) == 0 || model == nil {
+		return
+	}
+	v := reflect.ValueOf(model).Elem()
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	f := fieldByIndexAlloc(v, r.writer.versionIndex)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if increment {
+			f.SetInt(f.Int() + 1)
+		} else {
+			f.SetInt(1)
+		}
+	}
+}
+
+// versioned returns the context of a write conditional on the version of the model, which must be called before the version is incremented.
+func (r *Repository[T, K]) versioned(ctx context.Context, model *T) context.Context {
+	if len(r.versionJson) == 0 {
+		return ctx
+	}
+	var version int64
+	if v, ok := FieldByIndex(reflect.ValueOf(model), r.writer.versionIndex); ok {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			version = v.Int()
+		default:
+			return ctx
+		}
+	}
+	return withExpectedVersion(ctx, r.versionJson, version)
+}
+
+// copyWritten copies the written items, with their versions and audit fields, to the models.
+func (r *Repository[T, K]) copyWritten(models []T, items []T, indices []int) {
+	for _, i := range indices {
+		if i >= 0 && i < len(models) {
+			models[i] = items[i]
+		}
+	}
+}
+
+func equalIndex(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func toId(id interface{}) string {
+	if s, ok := id.(string); ok {
+		return s
+	}
+	return fmt.Sprint(id)
+}
@@ -0,0 +1,106 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type versionedUser struct {
+	Id      string `json:"id" bson:"_id"`
+	Name    string `json:"name"`
+	Version int32  `json:"version" es:",version"`
+}
+
+type namedVersionUser struct {
+	Id       string `json:"id" bson:"_id"`
+	Revision int    `json:"revision"`
+}
+
+type stringVersionUser struct {
+	Id      string `json:"id" bson:"_id"`
+	Version string `json:"version" es:",version"`
+}
+
+func TestSetVersion(t *testing.T) {
+	repository := NewRepository[versionedUser, string](newTestClient(t, &fakeTransport{}), "users")
+	user := versionedUser{Id: "1", Version: 5}
+	repository.setVersion(&user, false)
+	if user.Version != 1 {
+		t.Errorf("insert: version %d, expected 1", user.Version)
+	}
+	user.Version = 5
+	repository.setVersion(&user, true)
+	if user.Version != 6 {
+		t.Errorf("update: version %d, expected 6", user.Version)
+	}
+	repository.setVersion(nil, true)
+
+	named := NewRepository[namedVersionUser, string](newTestClient(t, &fakeTransport{}), "users", "Revision")
+	n := namedVersionUser{Id: "1", Revision: 2}
+	named.setVersion(&n, true)
+	if n.Revision != 3 {
+		t.Errorf("version by name: revision %d, expected 3", n.Revision)
+	}
+
+	unversioned := NewRepository[mappedUser, string](newTestClient(t, &fakeTransport{}), "users")
+	u := mappedUser{Id: "1", Name: "peter"}
+	unversioned.setVersion(&u, true)
+	if u != (mappedUser{Id: "1", Name: "peter"}) {
+		t.Errorf("model without version changed: %v", u)
+	}
+
+	notInt := NewRepository[stringVersionUser, string](newTestClient(t, &fakeTransport{}), "users")
+	s := stringVersionUser{Id: "1", Version: "a"}
+	notInt.setVersion(&s, true)
+	if s.Version != "a" {
+		t.Errorf("string version changed: %s", s.Version)
+	}
+}
+
+func TestVersioned(t *testing.T) {
+	ctx := context.Background()
+	repository := NewRepository[versionedUser, string](newTestClient(t, &fakeTransport{}), "users")
+	e := getExpectedVersion(repository.versioned(ctx, &versionedUser{Id: "1", Version: 7}))
+	if e == nil || e.field != "version" || e.version != 7 {
+		t.Errorf("expected version %+v, expected version 7 of the field version", e)
+	}
+	named := NewRepository[namedVersionUser, string](newTestClient(t, &fakeTransport{}), "users", "Revision")
+	e = getExpectedVersion(named.versioned(ctx, &namedVersionUser{Id: "1"}))
+	if e == nil || e.field != "revision" || e.version != 0 {
+		t.Errorf("expected version %+v, expected version 0 of the field revision", e)
+	}
+	unversioned := NewRepository[mappedUser, string](newTestClient(t, &fakeTransport{}), "users")
+	if e := getExpectedVersion(unversioned.versioned(ctx, &mappedUser{Id: "1"})); e != nil {
+		t.Errorf("expected version %+v for a model without version", e)
+	}
+	notInt := NewRepository[stringVersionUser, string](newTestClient(t, &fakeTransport{}), "users")
+	if e := getExpectedVersion(notInt.versioned(ctx, &stringVersionUser{Id: "1", Version: "1"})); e != nil {
+		t.Errorf("expected version %+v for a string version", e)
+	}
+}
+
+func TestExpectedVersionMatches(t *testing.T) {
+	tests := []struct {
+		version  int64
+		stored   interface{}
+		expected bool
+	}{
+		{0, nil, true},
+		{1, nil, false},
+		{3, float64(3), true},
+		{3, float64(4), false},
+		{3, json.Number("3"), true},
+		{3, json.Number("3.5"), false},
+		{3, int64(3), true},
+		{3, 3, true},
+		{3, 2, false},
+		{3, "3", false},
+	}
+	for _, tt := range tests {
+		e := &expectedVersion{field: "version", version: tt.version}
+		if got := e.matches(tt.stored); got != tt.expected {
+			t.Errorf("version %d, stored %v (%T): %v, expected %v", tt.version, tt.stored, tt.stored, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"reflect"
+)
+
+// Hit is a hit of a search response; its _source is kept raw, to be decoded directly into the model.
+type Hit struct {
+	Index     string                   `json:"_index"`
+	Id        string                   `json:"_id"`
+	Score     *float64                 `json:"_score"`
+	Source    json.RawMessage          `json:"_source"`
+	Fields    map[string][]interface{} `json:"fields,omitempty"`
+	Sort      []interface{}            `json:"sort,omitempty"`
+	InnerHits map[string]InnerHits     `json:"inner_hits,omitempty"`
+}
+
+type InnerHits struct {
+	Hits struct {
+		Hits []Hit `json:"hits"`
+	} `json:"hits"`
+}
+
+type SearchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []Hit `json:"hits"`
+	} `json:"hits"`
+}
+
+type GetResponse struct {
+	Index  string                   `json:"_index"`
+	Id     string                   `json:"_id"`
+	Found  bool                     `json:"found"`
+	Source json.RawMessage          `json:"_source"`
+	Fields map[string][]interface{} `json:"fields,omitempty"`
+}
+
+// DecodeSearchHits decodes the hits into results, a pointer to a slice, as DecodeHits, with the codec of the context. The string id field of a model (bson:"_id") is set from the _id of its hit, since it is not stored in _source.
+func DecodeSearchHits(ctx context.Context, hits []Hit, results interface{}) error {
+	codec := CodecFromContext(ctx)
+	slice := reflect.Indirect(reflect.ValueOf(results))
+	if slice.Kind() != reflect.Slice || !slice.CanSet() {
+		return errors.New("results must be a pointer to a slice")
+	}
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	modelType := elemType
+	if isPtr {
+		modelType = elemType.Elem()
+	}
+	var metas map[int]string
+	var innerHits map[string]int
+	if modelType.Kind() == reflect.Struct {
+		metas = getMetaFields(modelType)
+		innerHits = getInnerHitsFields(modelType)
+	}
+	list := reflect.MakeSlice(slice.Type(), 0, len(hits))
+	for _, hit := range hits {
+		model := reflect.New(modelType)
+		if err := decodeHit(ctx, codec, hit, model, metas, innerHits); err != nil {
+			return err
+		}
+		if isPtr {
+			list = reflect.Append(list, model)
+		} else {
+			list = reflect.Append(list, model.Elem())
+		}
+	}
+	slice.Set(list)
+	return nil
+}
+
+// DecodeSearchHit decodes the hit into result, a pointer to a model, as DecodeSearchHits.
+func DecodeSearchHit(ctx context.Context, hit Hit, result interface{}) error {
+	model := reflect.ValueOf(result)
+	if model.Kind() != reflect.Ptr || model.IsNil() {
+		return errors.New("result must be a pointer")
+	}
+	var metas map[int]string
+	var innerHits map[string]int
+	if modelType := model.Elem().Type(); modelType.Kind() == reflect.Struct {
+		metas = getMetaFields(modelType)
+		innerHits = getInnerHitsFields(modelType)
+	}
+	return decodeHit(ctx, CodecFromContext(ctx), hit, model, metas, innerHits)
+}
+
+func decodeHit(ctx context.Context, codec Codec, hit Hit, model reflect.Value, metas map[int]string, innerHits map[string]int) error {
+	if err := decodeSource(codec, hit.Id, hit.Source, hit.Fields, model.Interface()); err != nil {
+		return err
+	}
+	if err := DecryptModel(ctx, model.Interface()); err != nil {
+		return err
+	}
+	v := model.Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for index, meta := range metas {
+		setMetaField(v.Field(index), hitMeta(hit, meta))
+	}
+	for name, index := range innerHits {
+		if inner, ok := hit.InnerHits[name]; ok {
+			f := v.Field(index)
+			items := reflect.New(f.Type())
+			if err := DecodeSearchHits(withEncryptedType(ctx, nil), inner.Hits.Hits, items.Interface()); err != nil {
+				return err
+			}
+			f.Set(items.Elem())
+		}
+	}
+	return nil
+}
+
+// decodeSource decodes the _source into the model, merging the fields such as docvalue fields or script fields: a single value is unwrapped from its array.
+// The string id field of the model (bson:"_id") is set from the _id of the document if it is empty, since it is not stored in _source.
+func decodeSource(codec Codec, id string, source json.RawMessage, fields map[string][]interface{}, model interface{}) error {
+	if err := decodeFields(codec, source, fields, model); err != nil {
+		return err
+	}
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if len(id) == 0 || v.Kind() != reflect.Struct {
+		return nil
+	}
+	if index := GetMetadata(v.Type()).Id; len(index) > 0 {
+		if f := fieldByIndexAlloc(v, index); f.Kind() == reflect.String && f.CanSet() && len(f.String()) == 0 {
+			f.SetString(id)
+		}
+	}
+	return nil
+}
+
+// decodeFields decodes the _source into the model, then sets the fields of the model by their json names, or the keys of a map.
+func decodeFields(codec Codec, source json.RawMessage, fields map[string][]interface{}, model interface{}) error {
+	if len(source) > 0 {
+		if err := codec.Unmarshal(source, model); err != nil {
+			return err
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(model))
+	switch v.Kind() {
+	case reflect.Struct:
+		structFields := getStructFields(v.Type())
+		for k, values := range fields {
+			i, ok := structFields.byJson[k]
+			if !ok {
+				continue
+			}
+			f := fieldByIndexAlloc(v, structFields.list[i].Index)
+			if !f.IsValid() || !f.CanSet() {
+				continue
+			}
+			if err := setFieldValue(codec, f, fieldValue(values)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || !v.CanSet() {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for k, values := range fields {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := setFieldValue(codec, e, fieldValue(values)); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+		}
+	}
+	return nil
+}
+
+// fieldValue unwraps a single value from its array.
+func fieldValue(values []interface{}) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// setFieldValue sets the value if it can be assigned to the field, else it decodes the value into the field, such as a number into an int or a date into a time.Time.
+func setFieldValue(codec Codec, f reflect.Value, value interface{}) error {
+	if value == nil {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	if x := reflect.ValueOf(value); x.Type().AssignableTo(f.Type()) {
+		f.Set(x)
+		return nil
+	}
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, f.Addr().Interface())
+}
+
+func hitMeta(hit Hit, meta string) interface{} {
+	switch meta {
+	case "_id":
+		return hit.Id
+	case "_index":
+		return hit.Index
+	case "_score":
+		if hit.Score != nil {
+			return *hit.Score
+		}
+	case "_sort":
+		if len(hit.Sort) > 0 {
+			return hit.Sort[0]
+		}
+	case "_distance":
+		if values := hit.Fields["_distance"]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return nil
+}
+
+// responseError returns the error of an error response, with the type and the reason of the Elasticsearch error, such as a script error or a version conflict.
+func responseError(ctx context.Context, res *esapi.Response) error {
+	var r struct {
+		Error struct {
+			Type     string `json:"type"`
+			Reason   string `json:"reason"`
+			CausedBy struct {
+				Reason string `json:"reason"`
+			} `json:"caused_by"`
+		} `json:"error"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil || len(r.Error.Type) == 0 {
+		return fmt.Errorf("response error: %s", res.Status())
+	}
+	if len(r.Error.CausedBy.Reason) > 0 {
+		return fmt.Errorf("%s: %s: %s", r.Error.Type, r.Error.Reason, r.Error.CausedBy.Reason)
+	}
+	return fmt.Errorf("%s: %s", r.Error.Type, r.Error.Reason)
+}
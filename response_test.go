@@ -0,0 +1,50 @@
+package elasticsearch
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type place struct {
+	Id        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name"`
+	Rank      int       `json:"rank"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"createdAt"`
+	Profile   *profile  `json:"profile"`
+}
+
+type profile struct {
+	City string `json:"city"`
+}
+
+func TestDecodeSearchHitsFields(t *testing.T) {
+	hits := []Hit{{
+		Id:     "1",
+		Source: []byte(`{"name":"park","rank":1,"tags":["old"],"profile":{"city":"Hanoi"}}`),
+		Fields: map[string][]interface{}{
+			"rank":      {float64(7)},
+			"tags":      {"green", "quiet"},
+			"createdAt": {"2024-01-02T03:04:05Z"},
+			"unknown":   {"ignored"},
+		},
+	}}
+	var places []place
+	if err := DecodeSearchHits(context.Background(), hits, &places); err != nil {
+		t.Fatal(err)
+	}
+	expected := place{Id: "1", Name: "park", Rank: 7, Tags: []string{"green", "quiet"}, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Profile: &profile{City: "Hanoi"}}
+	if len(places) != 1 || !reflect.DeepEqual(places[0], expected) {
+		t.Errorf("places %+v, expected %+v", places, expected)
+	}
+
+	var maps []map[string]interface{}
+	if err := DecodeSearchHits(context.Background(), hits, &maps); err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 1 || maps[0]["name"] != "park" || maps[0]["rank"] != float64(7) || maps[0]["unknown"] != "ignored" {
+		t.Errorf("maps %v", maps)
+	}
+}
@@ -0,0 +1,157 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+	"reflect"
+)
+
+// SaveOne indexes the document as UpsertOne, but an existing document keeps the stored values of the fields named in created, such as the createdAt and createdBy which were empty in the model.
+// The document is created if it does not exist; else it is replaced only if it did not change since it was read, and the read is retried a few times on a concurrent change, then ErrVersionConflict is returned.
+// If the context has an expected version, an existing document is replaced only if its stored version is this version, else ErrVersionConflict is returned.
+// The stored values which are kept are set in the model, if it is a pointer, so that the model is the saved document.
+func SaveOne(ctx context.Context, es *elasticsearch.Client, indexName string, id string, model interface{}, created []string, options ...string) (int64, error) {
+	successful, stored, err := saveOne(ctx, es, indexName, reflect.TypeOf(model), id, model, created, options...)
+	if err != nil || len(stored) == 0 {
+		return successful, err
+	}
+	return successful, setStored(ctx, model, stored)
+}
+
+// saveOne saves the document as SaveOne, and returns the stored values which are kept, by their json names. The model may be the result of a mapping of a model of modelType, such as a map.
+func saveOne(ctx context.Context, es *elasticsearch.Client, indexName string, modelType reflect.Type, id string, model interface{}, created []string, options ...string) (int64, map[string]interface{}, error) {
+	expected := getExpectedVersion(ctx)
+	if len(created) == 0 && expected == nil {
+		successful, err := upsertOne(ctx, es, indexName, modelType, id, model, options...)
+		return successful, nil, err
+	}
+	includes := created
+	if expected != nil {
+		includes = append(append([]string{}, created...), expected.field)
+	}
+	body := documentBody(modelType, model)
+	if err := EncryptBody(ctx, modelType, body); err != nil {
+		return -1, nil, err
+	}
+	for i := 0; i < 3; i++ {
+		var stored map[string]interface{}
+		get := esapi.GetRequest{
+			Index:          indexName,
+			DocumentID:     id,
+			SourceIncludes: includes,
+		}
+		res, err := get.Do(ctx, es)
+		if err != nil {
+			return -1, nil, err
+		}
+		var r struct {
+			Found       bool                   `json:"found"`
+			SeqNo       int                    `json:"_seq_no"`
+			PrimaryTerm int                    `json:"_primary_term"`
+			Source      map[string]interface{} `json:"_source"`
+		}
+		if res.StatusCode != http.StatusNotFound {
+			if res.IsError() {
+				res.Body.Close()
+				return -1, nil, errors.New("response error")
+			}
+			if err := decode(ctx, res.Body, &r); err != nil {
+				res.Body.Close()
+				return -1, nil, err
+			}
+		}
+		res.Body.Close()
+		req := esapi.IndexRequest{
+			Index:      indexName,
+			DocumentID: id,
+			Refresh:    "true",
+		}
+		if r.Found {
+			if expected != nil && !expected.matches(r.Source[expected.field]) {
+				return -1, nil, ErrVersionConflict
+			}
+			stored = make(map[string]interface{})
+			for _, name := range created {
+				if v, ok := r.Source[name]; ok && v != nil {
+					body[name] = v
+					stored[name] = v
+				}
+			}
+			req.IfSeqNo, req.IfPrimaryTerm = &r.SeqNo, &r.PrimaryTerm
+		} else {
+			req.OpType = "create"
+		}
+		req.Body = NewReader(ctx, body)
+		if len(options) > 0 && len(options[0]) > 0 {
+			req.Pipeline = options[0]
+		}
+		successful, conflict, err := doSave(ctx, es, req)
+		if !conflict {
+			if err != nil {
+				return successful, nil, err
+			}
+			return successful, stored, nil
+		}
+	}
+	return -1, nil, ErrVersionConflict
+}
+
+func doSave(ctx context.Context, es *elasticsearch.Client, req esapi.IndexRequest) (int64, bool, error) {
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return -1, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusConflict {
+		return -1, true, nil
+	}
+	if res.IsError() {
+		return -1, false, errors.New("response error")
+	}
+	var r struct {
+		Shards struct {
+			Successful int64 `json:"successful"`
+		} `json:"_shards"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return -1, false, err
+	}
+	return r.Shards.Successful, false, nil
+}
+
+// setStored sets the stored values of a saved document, by their json names, in the model if it is a pointer to a struct. The encrypted values are decrypted.
+func setStored(ctx context.Context, model interface{}, stored map[string]interface{}) error {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	codec := CodecFromContext(ctx)
+	data, err := codec.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	decoded := reflect.New(v.Elem().Type())
+	if err := codec.Unmarshal(data, decoded.Interface()); err != nil {
+		return err
+	}
+	if err := DecryptModel(ctx, decoded.Interface()); err != nil {
+		return err
+	}
+	fields := getStructFields(v.Type())
+	for name := range stored {
+		i, ok := fields.byJson[name]
+		if !ok {
+			continue
+		}
+		index := fields.list[i].Index
+		if f, ok := FieldByIndex(decoded, index); ok {
+			if target := fieldByIndexAlloc(v, index); target.IsValid() && target.CanSet() {
+				target.Set(f)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestSaveOneConflict(t *testing.T) {
+	transport := &fakeTransport{respond: func(req *http.Request) (int, string) {
+		if req.Method == http.MethodGet {
+			return http.StatusOK, `{"_id":"1","found":true,"_seq_no":3,"_primary_term":1,"_source":{"createdBy":"u1"}}`
+		}
+		return http.StatusConflict, `{"error":{"type":"version_conflict_engine_exception"},"status":409}`
+	}}
+	_, err := SaveOne(context.Background(), newTestClient(t, transport), "users", "1", &event{Name: "login"}, []string{"createdBy"})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("error %v, expected ErrVersionConflict", err)
+	}
+	if len(transport.requests) != 6 {
+		t.Errorf("%d requests, expected 3 reads and 3 writes", len(transport.requests))
+	}
+}
@@ -0,0 +1,99 @@
+package elasticsearch
+
+import (
+	"context"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+)
+
+const retryOnConflict = 3
+
+// ScriptOne updates the document by a painless script, or by a stored script if Script.Id is set.
+// It returns 0 if the document does not exist, and the error of Elasticsearch, such as a script error, if the update fails.
+func ScriptOne(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, script Script) (int64, error) {
+	body := map[string]interface{}{"script": script}
+	return doScriptUpdate(ctx, es, indexName, documentID, body)
+}
+
+// ScriptUpsertOne runs the script on the document, or on the upsert document if the document does not exist (scripted upsert).
+// If upsert is nil, the script runs on an empty document.
+func ScriptUpsertOne(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, script Script, upsert interface{}) (int64, error) {
+	if upsert == nil {
+		upsert = map[string]interface{}{}
+	}
+	body := map[string]interface{}{
+		"script":          script,
+		"scripted_upsert": true,
+		"upsert":          upsert,
+	}
+	return doScriptUpdate(ctx, es, indexName, documentID, body)
+}
+
+func doScriptUpdate(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, body map[string]interface{}) (int64, error) {
+	retry := retryOnConflict
+	req := esapi.UpdateRequest{
+		Index:           indexName,
+		DocumentID:      documentID,
+		Body:            NewReader(ctx, body),
+		Refresh:         "true",
+		RetryOnConflict: &retry,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return -1, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+	if res.IsError() {
+		return -1, responseError(ctx, res)
+	}
+	var r map[string]interface{}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return -1, err
+	}
+	successful := int64(r["_shards"].(map[string]interface{})["successful"].(float64))
+	return successful, nil
+}
+
+// IncrementScript adds delta to the numeric field, which is initialized to delta if it does not exist.
+func IncrementScript(field string, delta interface{}) Script {
+	return NewScript("if (ctx._source[params.field] == null) { ctx._source[params.field] = params.delta } else { ctx._source[params.field] += params.delta }",
+		map[string]interface{}{"field": field, "delta": delta})
+}
+
+// AppendScript appends the values to the array field. If unique is true, the values already in the array are skipped.
+func AppendScript(field string, unique bool, values ...interface{}) Script {
+	return NewScript("if (ctx._source[params.field] == null) { ctx._source[params.field] = new ArrayList() } else if (!(ctx._source[params.field] instanceof List)) { ctx._source[params.field] = new ArrayList([ctx._source[params.field]]) } for (v in params.values) { if (!params.unique || !ctx._source[params.field].contains(v)) { ctx._source[params.field].add(v) } }",
+		map[string]interface{}{"field": field, "unique": unique, "values": values})
+}
+
+// RemoveScript removes all occurrences of the values from the array field.
+func RemoveScript(field string, values ...interface{}) Script {
+	return NewScript("if (ctx._source[params.field] instanceof List) { ctx._source[params.field].removeIf(v -> params.values.contains(v)) } else { ctx.op = 'noop' }",
+		map[string]interface{}{"field": field, "values": values})
+}
+
+// SetIfAbsentScript sets the field only if it does not exist or is null; otherwise the update is a noop.
+func SetIfAbsentScript(field string, value interface{}) Script {
+	return NewScript("if (ctx._source[params.field] == null) { ctx._source[params.field] = params.value } else { ctx.op = 'noop' }",
+		map[string]interface{}{"field": field, "value": value})
+}
+
+func IncrementOne(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, field string, delta interface{}) (int64, error) {
+	return ScriptOne(ctx, es, indexName, documentID, IncrementScript(field, delta))
+}
+
+func AppendOne(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, field string, values ...interface{}) (int64, error) {
+	return ScriptOne(ctx, es, indexName, documentID, AppendScript(field, false, values...))
+}
+
+func RemoveOne(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, field string, values ...interface{}) (int64, error) {
+	return ScriptOne(ctx, es, indexName, documentID, RemoveScript(field, values...))
+}
+
+func SetIfAbsentOne(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, field string, value interface{}) (int64, error) {
+	return ScriptOne(ctx, es, indexName, documentID, SetIfAbsentScript(field, value))
+}
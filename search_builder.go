@@ -3,13 +3,21 @@ package elasticsearch
 import (
 	"context"
 	"github.com/elastic/go-elasticsearch/v7"
+	"reflect"
 )
 
 type SearchBuilder struct {
 	Client     *elasticsearch.Client
 	IndexName  string
+	GetIndices func(searchModel interface{}) []string
+	Projection *Projection
+	Codec      Codec
+	Encryptor  *Encryptor
+	SoftDelete *SoftDelete
+	ModelType  reflect.Type
 	BuildQuery func(searchModel interface{}) map[string]interface{}
 	GetSort    func(m interface{}) string
+	BuildSort  func(s string) []string
 	Map        func(ctx context.Context, model interface{}) (interface{}, error)
 }
 
@@ -20,16 +28,100 @@ func NewSearchBuilder(client *elasticsearch.Client, indexName string, buildQuery
 	}
 	return &SearchBuilder{Client: client, IndexName: indexName, BuildQuery: buildQuery, GetSort: getSort, Map: mp}
 }
-func (b *SearchBuilder) Search(ctx context.Context, sm interface{}, results interface{}, pageIndex int64, pageSize int64, options ...int64) (int64, error) {
+
+// NewSearchBuilderWithProjection creates a search builder which returns only the fields of resultType, a smaller struct than the model of the index.
+func NewSearchBuilderWithProjection(client *elasticsearch.Client, indexName string, resultType reflect.Type, buildQuery func(interface{}) map[string]interface{}, getSort func(m interface{}) string, options ...func(context.Context, interface{}) (interface{}, error)) *SearchBuilder {
+	builder := NewSearchBuilder(client, indexName, buildQuery, getSort, options...)
+	projection := NewProjection(resultType)
+	builder.Projection = &projection
+	return builder
+}
+
+// NewTimeSearchBuilder creates a search builder on time-based rolling indices. By default, it searches on all indices of timeIndex; getIndices can narrow the search to the indices of a period, for example with TimeIndex.Range or TimeIndex.Recent.
+func NewTimeSearchBuilder(client *elasticsearch.Client, timeIndex *TimeIndex, buildQuery func(interface{}) map[string]interface{}, getSort func(m interface{}) string, getIndices func(searchModel interface{}) []string, options ...func(context.Context, interface{}) (interface{}, error)) *SearchBuilder {
+	builder := NewSearchBuilder(client, timeIndex.Wildcard(), buildQuery, getSort, options...)
+	builder.GetIndices = getIndices
+	return builder
+}
+
+// context returns the context with the codec and the encryptor of the builder, unless the context already has them.
+func (b *SearchBuilder) context(ctx context.Context) context.Context {
+	ctx = withDefaultCodec(ctx, b.Codec)
+	return withDefaultEncryptor(ctx, b.Encryptor)
+}
+
+// Count returns only the number of documents matching the search model, for example for badges and dashboards.
+func (b *SearchBuilder) Count(ctx context.Context, sm interface{}) (int64, error) {
+	ctx = b.context(ctx)
+	query, err := b.buildQuery(ctx, sm)
+	if err != nil {
+		return 0, err
+	}
+	return CountWithIndices(ctx, b.Client, b.indices(sm), query)
+}
+
+// Request builds the search of a page to run with other searches by MultiSearch. The query is built as for Search, excluding the soft-deleted documents unless the context is WithDeleted.
+func (b *SearchBuilder) Request(ctx context.Context, sm interface{}, results interface{}, pageIndex int64, pageSize int64) (MultiSearchRequest, error) {
+	ctx = b.context(ctx)
+	query, err := b.buildQuery(ctx, sm)
+	if err != nil {
+		return MultiSearchRequest{}, err
+	}
+	req := NewMultiSearchRequest(b.IndexName, query, b.buildSort(b.GetSort(sm)), pageIndex, pageSize, results, b.Map)
+	req.Indices = b.indices(sm)
+	req.Projection = b.Projection
+	req.ModelType = b.ModelType
+	return req, nil
+}
+
+// buildQuery excludes the soft-deleted documents if SoftDelete is set, unless the context is WithDeleted.
+// If ModelType is set, the equality clauses on its encrypted fields are translated by EncryptQuery.
+func (b *SearchBuilder) buildQuery(ctx context.Context, sm interface{}) (map[string]interface{}, error) {
 	query := b.BuildQuery(sm)
+	if b.ModelType != nil {
+		var err error
+		if query, err = EncryptQuery(ctx, b.ModelType, query); err != nil {
+			return nil, err
+		}
+	}
+	if b.SoftDelete.excludes(ctx) {
+		return b.SoftDelete.Exclude(query), nil
+	}
+	return query, nil
+}
+
+// buildSort converts the sort of the search model by BuildSort, or by the BuildSort of the builder if it is set, for example BuildSortOrders to sort with the orders and by distance.
+func (b *SearchBuilder) buildSort(s string) []string {
+	if b.BuildSort != nil {
+		return b.BuildSort(s)
+	}
+	return BuildSort(s)
+}
+
+func (b *SearchBuilder) indices(sm interface{}) []string {
+	if b.GetIndices != nil {
+		if list := b.GetIndices(sm); len(list) > 0 {
+			return list
+		}
+	}
+	return []string{b.IndexName}
+}
+
+func (b *SearchBuilder) Search(ctx context.Context, sm interface{}, results interface{}, pageIndex int64, pageSize int64, options ...int64) (int64, error) {
+	ctx = b.context(ctx)
+	ctx = withEncryptedType(ctx, b.ModelType)
+	query, err := b.buildQuery(ctx, sm)
+	if err != nil {
+		return 0, err
+	}
 	s := b.GetSort(sm)
 	var sort []string
-	sort = BuildSort(s)
+	sort = b.buildSort(s)
 	var firstPageSize int64
 	if len(options) > 0 && options[0] > 0 {
 		firstPageSize = options[0]
 	} else {
 		firstPageSize = 0
 	}
-	return BuildSearchResult(ctx, b.Client, results, b.IndexName, query, sort, pageIndex, pageSize, firstPageSize, b.Map)
+	return BuildSearchResultWithProjection(ctx, b.Client, results, b.indices(sm), b.Projection, query, sort, pageIndex, pageSize, firstPageSize, b.Map)
 }
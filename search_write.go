@@ -27,11 +27,13 @@ func NewSearchWriterWithMapper(client *elasticsearch.Client, indexName string, m
 		versionField = options[0]
 	}
 	writer := NewWriterWithMapper(client, indexName, modelType, mapper, versionField)
-	var searcher *Searcher
+	var builder *SearchBuilder
 	if mapper != nil {
-		searcher = NewSearcherWithQuery(client, indexName, buildQuery, getSort, mapper.DbToModel)
+		builder = NewSearchBuilder(client, indexName, buildQuery, getSort, mapper.DbToModel)
 	} else {
-		searcher = NewSearcherWithQuery(client, indexName, buildQuery, getSort)
+		builder = NewSearchBuilder(client, indexName, buildQuery, getSort)
 	}
-	return searcher, writer
+	builder.SoftDelete = writer.SoftDelete
+	builder.ModelType = modelType
+	return NewSearcher(builder.Search), writer
 }
@@ -21,3 +21,8 @@ func NewSearcherWithQuery(client *elasticsearch.Client, indexName string, buildQ
 	builder := NewSearchBuilder(client, indexName, buildQuery, getSort, options...)
 	return NewSearcher(builder.Search)
 }
+
+func NewTimeSearcher(client *elasticsearch.Client, timeIndex *TimeIndex, buildQuery func(interface{}) map[string]interface{}, getSort func(m interface{}) string, getIndices func(searchModel interface{}) []string, options ...func(context.Context, interface{}) (interface{}, error)) *Searcher {
+	builder := NewTimeSearchBuilder(client, timeIndex, buildQuery, getSort, getIndices, options...)
+	return NewSearcher(builder.Search)
+}
@@ -0,0 +1,270 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"log"
+	"reflect"
+	"time"
+)
+
+type deletedKey struct{}
+
+// SoftDelete is the field marking the soft-deleted documents, tagged es:",deleted" in the model, such as es:"boolean,deleted" or es:"date,deleted".
+// A bool field is a flag; a field of time (time.Time, *time.Time, milliseconds or a RFC 3339 string) keeps the time of the deletion, to purge the old deletions.
+// A document is deleted if the field is set to a non-zero value: false, a zero time, 0 and "" are not deleted.
+type SoftDelete struct {
+	Field string
+	Index []int
+	Type  reflect.Type
+}
+
+// NewSoftDelete returns the soft delete field of the model, or nil if the model has no field tagged es:",deleted".
+func NewSoftDelete(modelType reflect.Type) *SoftDelete {
+	for _, field := range GetStructFields(modelType) {
+		if hasFlag(field.Tag.Get("es"), "deleted") {
+			return &SoftDelete{Field: field.JsonName, Index: field.Index, Type: field.Type}
+		}
+	}
+	return nil
+}
+
+// WithDeleted returns a context which makes the loaders and the search builders return the soft-deleted documents too.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedKey{}, true)
+}
+
+func IncludesDeleted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	deleted, _ := ctx.Value(deletedKey{}).(bool)
+	return deleted
+}
+
+// excludes reports whether the soft-deleted documents are excluded for this call.
+func (s *SoftDelete) excludes(ctx context.Context) bool {
+	return s != nil && !IncludesDeleted(ctx)
+}
+
+func (s *SoftDelete) isTime() bool {
+	t := s.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Bool
+}
+
+// zero returns the zero value of a field of time, as it is stored in the source.
+func (s *SoftDelete) zero() interface{} {
+	t := s.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return time.Time{}.Format(time.RFC3339)
+	case t.Kind() == reflect.String:
+		return ""
+	case t.Kind() == reflect.Bool:
+		return false
+	}
+	return 0
+}
+
+// zeros returns the values of a field of time which are not deleted, as isDeletedValue: the zero time, and "" for a string.
+func (s *SoftDelete) zeros() []interface{} {
+	t := s.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return []interface{}{time.Time{}.Format(time.RFC3339)}
+	case t.Kind() == reflect.String:
+		return []interface{}{"", time.Time{}.Format(time.RFC3339)}
+	}
+	return []interface{}{0}
+}
+
+// Deleted returns the query clause matching the soft-deleted documents.
+func (s *SoftDelete) Deleted() map[string]interface{} {
+	if !s.isTime() {
+		return map[string]interface{}{"term": map[string]interface{}{s.Field: true}}
+	}
+	exists := map[string]interface{}{"exists": map[string]interface{}{"field": s.Field}}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter":   []interface{}{exists},
+			"must_not": []interface{}{map[string]interface{}{"terms": map[string]interface{}{s.Field: s.zeros()}}},
+		},
+	}
+}
+
+// Exclude returns the query, a search body or a query clause, excluding the soft-deleted documents.
+func (s *SoftDelete) Exclude(query map[string]interface{}) map[string]interface{} {
+	body := BuildQueryBody(query)
+	body["query"] = map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter":   []interface{}{body["query"]},
+			"must_not": []interface{}{s.Deleted()},
+		},
+	}
+	return body
+}
+
+// IsDeleted reports whether the model is soft-deleted.
+func (s *SoftDelete) IsDeleted(model interface{}) bool {
+	v, ok := FieldByIndex(reflect.ValueOf(model), s.Index)
+	if !ok {
+		return false
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return isDeletedValue(v.String())
+	}
+	return !v.IsZero()
+}
+
+func (s *SoftDelete) isDeletedSource(codec Codec, source json.RawMessage) (bool, error) {
+	var doc map[string]interface{}
+	if err := codec.Unmarshal(source, &doc); err != nil {
+		return false, err
+	}
+	return isDeletedValue(doc[s.Field]), nil
+}
+
+// isDeletedValue reports whether the decoded value of the soft delete field is set to a non-zero value, as IsDeleted.
+func isDeletedValue(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case json.Number:
+		f, err := x.Float64()
+		return err != nil || f != 0
+	case string:
+		if len(x) == 0 {
+			return false
+		}
+		t, err := time.Parse(time.RFC3339, x)
+		return err != nil || !t.IsZero()
+	}
+	return !reflect.ValueOf(v).IsZero()
+}
+
+// removeDeleted removes the soft-deleted documents from the sources.
+func (s *SoftDelete) removeDeleted(codec Codec, sources map[string]json.RawMessage) error {
+	for id, source := range sources {
+		deleted, err := s.isDeletedSource(codec, source)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			delete(sources, id)
+		}
+	}
+	return nil
+}
+
+// DeleteScript marks the document as deleted, with updatedAt and updatedBy of the model type; a document already deleted keeps the time of its deletion.
+func (s *SoftDelete) DeleteScript(ctx context.Context, modelType reflect.Type) Script {
+	var value interface{} = true
+	if s.isTime() {
+		if v, ok := auditTimeValue(s.Type, auditNow(ctx)); ok {
+			value = v.Interface()
+		}
+	}
+	doc := make(map[string]interface{})
+	SetAuditMap(ctx, modelType, doc)
+	return NewScript("if (ctx._source[params.field] == null || ctx._source[params.field] == false || ctx._source[params.field] == params.zero) { ctx._source[params.field] = params.value; ctx._source.putAll(params.doc) } else { ctx.op = 'noop' }",
+		map[string]interface{}{"field": s.Field, "value": value, "zero": s.zero(), "doc": doc})
+}
+
+// RestoreScript removes the deleted mark of the document, with updatedAt and updatedBy of the model type.
+func (s *SoftDelete) RestoreScript(ctx context.Context, modelType reflect.Type) Script {
+	doc := make(map[string]interface{})
+	SetAuditMap(ctx, modelType, doc)
+	return NewScript("if (ctx._source[params.field] == null || ctx._source[params.field] == false || ctx._source[params.field] == params.zero) { ctx.op = 'noop' } else { ctx._source.remove(params.field); ctx._source.putAll(params.doc) }",
+		map[string]interface{}{"field": s.Field, "zero": s.zero(), "doc": doc})
+}
+
+// PurgeDeleted deletes permanently the documents soft-deleted before the time. The soft delete field must be a field of time.
+func PurgeDeleted(ctx context.Context, es *elasticsearch.Client, indexName string, softDelete *SoftDelete, before time.Time, options ...ByQueryOptions) (*ByQueryResult, error) {
+	query, err := purgeQuery(softDelete, before)
+	if err != nil {
+		return nil, err
+	}
+	return DeleteByQuery(ctx, es, indexName, query, options...)
+}
+
+// purgeQuery returns the query clause matching the documents soft-deleted before the time, after the zero time, which is not deleted.
+func purgeQuery(softDelete *SoftDelete, before time.Time) (map[string]interface{}, error) {
+	if softDelete == nil || !softDelete.isTime() {
+		return nil, errors.New("the soft delete field must be a field of time to purge the old deletions")
+	}
+	value, ok := auditTimeValue(softDelete.Type, before)
+	if !ok {
+		return nil, errors.New("unsupported type of the soft delete field")
+	}
+	zeros := softDelete.zeros()
+	rng := map[string]interface{}{"lt": value.Interface(), "gt": zeros[len(zeros)-1]}
+	return map[string]interface{}{
+		"range": map[string]interface{}{softDelete.Field: rng},
+	}, nil
+}
+
+// PurgeJob purges periodically the documents soft-deleted for longer than Retention.
+type PurgeJob struct {
+	Client     *elasticsearch.Client
+	IndexName  string
+	SoftDelete *SoftDelete
+	Retention  time.Duration
+	Interval   time.Duration
+	Options    ByQueryOptions
+}
+
+func NewPurgeJob(client *elasticsearch.Client, indexName string, modelType reflect.Type, retention time.Duration, interval time.Duration) *PurgeJob {
+	return &PurgeJob{Client: client, IndexName: indexName, SoftDelete: NewSoftDelete(modelType), Retention: retention, Interval: interval}
+}
+
+// Run purges the old deletions once and returns the number of deleted documents.
+func (j *PurgeJob) Run(ctx context.Context) (int64, error) {
+	r, err := PurgeDeleted(ctx, j.Client, j.IndexName, j.SoftDelete, auditNow(ctx).Add(-j.Retention), j.Options)
+	if err != nil {
+		return 0, err
+	}
+	return r.Deleted, nil
+}
+
+// Start runs the job at each interval until the context is done. It returns an error without running the job if the interval is not positive.
+func (j *PurgeJob) Start(ctx context.Context) error {
+	if j.Interval <= 0 {
+		return fmt.Errorf("invalid interval %s of the purge of %s", j.Interval, j.IndexName)
+	}
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		if deleted, err := j.Run(ctx); err != nil {
+			log.Printf("purge of %s: %s", j.IndexName, err.Error())
+		} else if deleted > 0 {
+			log.Printf("purge of %s: %d documents deleted", j.IndexName, deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type boolDeleted struct {
+	Id      string `json:"id" bson:"_id"`
+	Deleted bool   `json:"deleted" es:"boolean,deleted"`
+}
+
+type boolPointerDeleted struct {
+	Id      string `json:"id" bson:"_id"`
+	Deleted *bool  `json:"deleted" es:"boolean,deleted"`
+}
+
+type timeDeleted struct {
+	Id        string    `json:"id" bson:"_id"`
+	DeletedAt time.Time `json:"deletedAt" es:"date,deleted"`
+}
+
+type timePointerDeleted struct {
+	Id        string     `json:"id" bson:"_id"`
+	DeletedAt *time.Time `json:"deletedAt" es:"date,deleted"`
+}
+
+type millisDeleted struct {
+	Id        string `json:"id" bson:"_id"`
+	DeletedAt int64  `json:"deletedAt" es:"long,deleted"`
+}
+
+type stringDeleted struct {
+	Id        string `json:"id" bson:"_id"`
+	DeletedAt string `json:"deletedAt" es:"date,deleted"`
+}
+
+func TestSoftDeleteDeleted(t *testing.T) {
+	zeroTime := time.Time{}.Format(time.RFC3339)
+	timeClause := func(field string, zeros ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"bool": map[string]interface{}{
+			"filter":   []interface{}{map[string]interface{}{"exists": map[string]interface{}{"field": field}}},
+			"must_not": []interface{}{map[string]interface{}{"terms": map[string]interface{}{field: zeros}}},
+		}}
+	}
+	tests := []struct {
+		name      string
+		modelType reflect.Type
+		expected  map[string]interface{}
+	}{
+		{"bool", reflect.TypeOf(boolDeleted{}), map[string]interface{}{"term": map[string]interface{}{"deleted": true}}},
+		{"bool pointer", reflect.TypeOf(boolPointerDeleted{}), map[string]interface{}{"term": map[string]interface{}{"deleted": true}}},
+		{"time", reflect.TypeOf(timeDeleted{}), timeClause("deletedAt", zeroTime)},
+		{"time pointer", reflect.TypeOf(timePointerDeleted{}), timeClause("deletedAt", zeroTime)},
+		{"milliseconds", reflect.TypeOf(millisDeleted{}), timeClause("deletedAt", 0)},
+		{"string", reflect.TypeOf(stringDeleted{}), timeClause("deletedAt", "", zeroTime)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if clause := NewSoftDelete(tt.modelType).Deleted(); !reflect.DeepEqual(clause, tt.expected) {
+				t.Errorf("clause %v, expected %v", clause, tt.expected)
+			}
+		})
+	}
+	if s := NewSoftDelete(reflect.TypeOf(mappedUser{})); s != nil {
+		t.Errorf("soft delete %v for a model without deleted field", s)
+	}
+}
+
+func TestIsDeletedValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected bool
+	}{
+		{nil, false},
+		{false, false},
+		{true, true},
+		{float64(0), false},
+		{float64(1700000000000), true},
+		{json.Number("0"), false},
+		{json.Number("1700000000000"), true},
+		{"", false},
+		{time.Time{}.Format(time.RFC3339), false},
+		{"2024-01-02T03:04:05Z", true},
+		{"not a time", true},
+	}
+	for _, tt := range tests {
+		if deleted := isDeletedValue(tt.value); deleted != tt.expected {
+			t.Errorf("%v (%T): deleted %v, expected %v", tt.value, tt.value, deleted, tt.expected)
+		}
+	}
+}
+
+func TestPurgeQuery(t *testing.T) {
+	before := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	zeroTime := time.Time{}.Format(time.RFC3339)
+	tests := []struct {
+		name      string
+		modelType reflect.Type
+		expected  map[string]interface{}
+	}{
+		{"time", reflect.TypeOf(timeDeleted{}), map[string]interface{}{"lt": before, "gt": zeroTime}},
+		{"time pointer", reflect.TypeOf(timePointerDeleted{}), map[string]interface{}{"lt": &before, "gt": zeroTime}},
+		{"milliseconds", reflect.TypeOf(millisDeleted{}), map[string]interface{}{"lt": before.UnixNano() / int64(time.Millisecond), "gt": 0}},
+		{"string", reflect.TypeOf(stringDeleted{}), map[string]interface{}{"lt": "2024-01-02T03:04:05Z", "gt": zeroTime}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := purgeQuery(NewSoftDelete(tt.modelType), before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]interface{}{"range": map[string]interface{}{"deletedAt": tt.expected}}
+			if !reflect.DeepEqual(query, expected) {
+				t.Errorf("query %v, expected %v", query, expected)
+			}
+		})
+	}
+	for _, modelType := range []reflect.Type{reflect.TypeOf(boolDeleted{}), reflect.TypeOf(boolPointerDeleted{})} {
+		if _, err := purgeQuery(NewSoftDelete(modelType), before); err == nil {
+			t.Errorf("%s: expected an error for a bool field", modelType.Name())
+		}
+	}
+	if _, err := purgeQuery(nil, before); err == nil {
+		t.Error("expected an error without soft delete field")
+	}
+}
+
+func TestSoftDeleteIsDeleted(t *testing.T) {
+	yes, no := true, false
+	now := time.Now()
+	tests := []struct {
+		model    interface{}
+		expected bool
+	}{
+		{&boolDeleted{Deleted: true}, true},
+		{&boolDeleted{}, false},
+		{&boolPointerDeleted{Deleted: &yes}, true},
+		{&boolPointerDeleted{Deleted: &no}, false},
+		{&boolPointerDeleted{}, false},
+		{&timeDeleted{DeletedAt: now}, true},
+		{&timeDeleted{}, false},
+		{&timePointerDeleted{DeletedAt: &now}, true},
+		{&timePointerDeleted{DeletedAt: &time.Time{}}, false},
+		{&timePointerDeleted{}, false},
+		{&millisDeleted{DeletedAt: 1700000000000}, true},
+		{&millisDeleted{}, false},
+		{&stringDeleted{DeletedAt: "2024-01-02T03:04:05Z"}, true},
+		{&stringDeleted{DeletedAt: time.Time{}.Format(time.RFC3339)}, false},
+		{&stringDeleted{}, false},
+	}
+	for _, tt := range tests {
+		s := NewSoftDelete(reflect.TypeOf(tt.model).Elem())
+		if deleted := s.IsDeleted(tt.model); deleted != tt.expected {
+			t.Errorf("%+v: deleted %v, expected %v", tt.model, deleted, tt.expected)
+		}
+	}
+}
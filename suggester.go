@@ -0,0 +1,224 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+const suggestionName = "suggestion"
+
+type Suggestion struct {
+	Text        string  `json:"text"`
+	Score       float64 `json:"score"`
+	Id          string  `json:"id,omitempty"`
+	Highlighted string  `json:"highlighted,omitempty"`
+	Frequency   int64   `json:"freq,omitempty"`
+}
+
+type suggestOption struct {
+	Text        string  `json:"text"`
+	Id          string  `json:"_id"`
+	Score       float64 `json:"score"`
+	DocScore    float64 `json:"_score"`
+	Highlighted string  `json:"highlighted"`
+	Freq        int64   `json:"freq"`
+}
+
+type suggestEntry struct {
+	Text    string          `json:"text"`
+	Options []suggestOption `json:"options"`
+}
+
+// Suggester returns type-ahead suggestions for a prefix, from a "completion" field (default) or from a "search_as_you_type" field.
+type Suggester struct {
+	client    *elasticsearch.Client
+	indexName string
+	field     string
+	size      int
+	Type      string
+	Contexts  map[string][]string
+	Fuzzy     bool
+}
+
+func NewSuggester(client *elasticsearch.Client, indexName string, field string, options ...int) *Suggester {
+	size := 10
+	if len(options) > 0 && options[0] > 0 {
+		size = options[0]
+	}
+	return &Suggester{client: client, indexName: indexName, field: field, size: size, Type: "completion"}
+}
+
+func NewSearchAsYouTypeSuggester(client *elasticsearch.Client, indexName string, field string, options ...int) *Suggester {
+	suggester := NewSuggester(client, indexName, field, options...)
+	suggester.Type = "search_as_you_type"
+	return suggester
+}
+
+func (s *Suggester) Suggest(ctx context.Context, prefix string) ([]Suggestion, error) {
+	if s.Type == "search_as_you_type" {
+		return SearchAsYouType(ctx, s.client, s.indexName, s.field, prefix, s.size)
+	}
+	return CompletionSuggest(ctx, s.client, s.indexName, s.field, prefix, s.size, s.Contexts, s.Fuzzy)
+}
+
+// Values returns the texts of the suggestions, ranked by score.
+func (s *Suggester) Values(ctx context.Context, prefix string) ([]string, error) {
+	var values []string
+	suggestions, err := s.Suggest(ctx, prefix)
+	if err != nil {
+		return values, err
+	}
+	for _, suggestion := range suggestions {
+		values = append(values, suggestion.Text)
+	}
+	return values, nil
+}
+
+// CompletionSuggest returns the suggestions of a completion field for the prefix, filtered by the contexts if the field has contexts.
+func CompletionSuggest(ctx context.Context, es *elasticsearch.Client, indexName string, field string, prefix string, size int, contexts map[string][]string, fuzzy bool) ([]Suggestion, error) {
+	completion := map[string]interface{}{
+		"field":           field,
+		"size":            size,
+		"skip_duplicates": true,
+	}
+	if len(contexts) > 0 {
+		completion["contexts"] = contexts
+	}
+	if fuzzy {
+		completion["fuzzy"] = map[string]interface{}{"fuzziness": "AUTO"}
+	}
+	suggest := map[string]interface{}{"prefix": prefix, "completion": completion}
+	entries, err := doSuggest(ctx, es, indexName, suggest)
+	if err != nil {
+		return nil, err
+	}
+	var suggestions []Suggestion
+	for _, entry := range entries {
+		for _, o := range entry.Options {
+			suggestions = append(suggestions, Suggestion{Text: o.Text, Score: o.DocScore, Id: o.Id})
+		}
+	}
+	return suggestions, nil
+}
+
+// TermSuggest returns the corrections of every term of the text, by term.
+func TermSuggest(ctx context.Context, es *elasticsearch.Client, indexName string, field string, text string, size int) (map[string][]Suggestion, error) {
+	suggest := map[string]interface{}{
+		"text": text,
+		"term": map[string]interface{}{"field": field, "size": size, "suggest_mode": "popular"},
+	}
+	entries, err := doSuggest(ctx, es, indexName, suggest)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make(map[string][]Suggestion)
+	for _, entry := range entries {
+		for _, o := range entry.Options {
+			suggestions[entry.Text] = append(suggestions[entry.Text], Suggestion{Text: o.Text, Score: o.Score, Frequency: o.Freq})
+		}
+	}
+	return suggestions, nil
+}
+
+// PhraseSuggest returns the "did you mean" corrections of the whole text. The corrected terms are highlighted with <em> tags.
+func PhraseSuggest(ctx context.Context, es *elasticsearch.Client, indexName string, field string, text string, size int) ([]Suggestion, error) {
+	suggest := map[string]interface{}{
+		"text": text,
+		"phrase": map[string]interface{}{
+			"field":     field,
+			"size":      size,
+			"gram_size": 3,
+			"direct_generator": []map[string]interface{}{
+				{"field": field, "suggest_mode": "always"},
+			},
+			"highlight": map[string]interface{}{"pre_tag": "<em>", "post_tag": "</em>"},
+		},
+	}
+	entries, err := doSuggest(ctx, es, indexName, suggest)
+	if err != nil {
+		return nil, err
+	}
+	var suggestions []Suggestion
+	for _, entry := range entries {
+		for _, o := range entry.Options {
+			suggestions = append(suggestions, Suggestion{Text: o.Text, Score: o.Score, Highlighted: o.Highlighted})
+		}
+	}
+	return suggestions, nil
+}
+
+// SearchAsYouType returns the values of a search_as_you_type field matching the prefix, ranked by score.
+func SearchAsYouType(ctx context.Context, es *elasticsearch.Client, indexName string, field string, prefix string, size int) ([]Suggestion, error) {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  prefix,
+				"type":   "bool_prefix",
+				"fields": []string{field, field + "._2gram", field + "._3gram"},
+			},
+		},
+		"_source": []string{field},
+		"size":    size,
+	}
+	req := esapi.SearchRequest{
+		Index: pathIndices(indexName),
+		Body:  NewReader(ctx, body),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		Hits struct {
+			Hits []struct {
+				Id     string                 `json:"_id"`
+				Score  float64                `json:"_score"`
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	var suggestions []Suggestion
+	for _, hit := range r.Hits.Hits {
+		if v, ok := hit.Source[field]; ok && v != nil {
+			suggestions = append(suggestions, Suggestion{Text: fmt.Sprintf("%v", v), Score: hit.Score, Id: hit.Id})
+		}
+	}
+	return suggestions, nil
+}
+
+func doSuggest(ctx context.Context, es *elasticsearch.Client, indexName string, suggest map[string]interface{}) ([]suggestEntry, error) {
+	body := map[string]interface{}{
+		"suggest": map[string]interface{}{suggestionName: suggest},
+		"_source": false,
+		"size":    0,
+	}
+	req := esapi.SearchRequest{
+		Index: pathIndices(indexName),
+		Body:  NewReader(ctx, body),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		Suggest map[string][]suggestEntry `json:"suggest"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	return r.Suggest[suggestionName], nil
+}
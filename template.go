@@ -0,0 +1,198 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+	"reflect"
+)
+
+type Template struct {
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Mappings map[string]interface{} `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+type IndexTemplate struct {
+	IndexPatterns []string               `json:"index_patterns"`
+	Template      *Template              `json:"template,omitempty"`
+	ComposedOf    []string               `json:"composed_of,omitempty"`
+	Priority      int64                  `json:"priority,omitempty"`
+	Version       int64                  `json:"version,omitempty"`
+	DataStream    map[string]interface{} `json:"data_stream,omitempty"`
+	Meta          map[string]interface{} `json:"_meta,omitempty"`
+}
+
+type ComponentTemplate struct {
+	Template Template               `json:"template"`
+	Version  int64                  `json:"version,omitempty"`
+	Meta     map[string]interface{} `json:"_meta,omitempty"`
+}
+
+// NewTemplate creates a template with the mappings generated from the model, see BuildMapping.
+func NewTemplate(modelType reflect.Type, options ...map[string]interface{}) Template {
+	template := Template{Mappings: BuildMapping(modelType)}
+	if len(options) > 0 {
+		template.Settings = options[0]
+	}
+	return template
+}
+
+func PutIndexTemplate(ctx context.Context, es *elasticsearch.Client, name string, template IndexTemplate) (bool, error) {
+	req := esapi.IndicesPutIndexTemplateRequest{
+		Name: name,
+		Body: NewReader(ctx, template),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func GetIndexTemplate(ctx context.Context, es *elasticsearch.Client, name string) (*IndexTemplate, error) {
+	req := esapi.IndicesGetIndexTemplateRequest{
+		Name: name,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		IndexTemplates []struct {
+			Name          string        `json:"name"`
+			IndexTemplate IndexTemplate `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	for _, t := range r.IndexTemplates {
+		if t.Name == name {
+			return &t.IndexTemplate, nil
+		}
+	}
+	return nil, nil
+}
+
+func DeleteIndexTemplate(ctx context.Context, es *elasticsearch.Client, name string) (bool, error) {
+	req := esapi.IndicesDeleteIndexTemplateRequest{
+		Name: name,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func PutComponentTemplate(ctx context.Context, es *elasticsearch.Client, name string, template ComponentTemplate) (bool, error) {
+	req := esapi.ClusterPutComponentTemplateRequest{
+		Name: name,
+		Body: NewReader(ctx, template),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+func GetComponentTemplate(ctx context.Context, es *elasticsearch.Client, name string) (*ComponentTemplate, error) {
+	req := esapi.ClusterGetComponentTemplateRequest{
+		Name: []string{name},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, errors.New("response error")
+	}
+	var r struct {
+		ComponentTemplates []struct {
+			Name              string            `json:"name"`
+			ComponentTemplate ComponentTemplate `json:"component_template"`
+		} `json:"component_templates"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return nil, err
+	}
+	for _, t := range r.ComponentTemplates {
+		if t.Name == name {
+			return &t.ComponentTemplate, nil
+		}
+	}
+	return nil, nil
+}
+
+func DeleteComponentTemplate(ctx context.Context, es *elasticsearch.Client, name string) (bool, error) {
+	req := esapi.ClusterDeleteComponentTemplateRequest{
+		Name: name,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, errors.New("response error")
+	}
+	return IsAcknowledged(ctx, res)
+}
+
+// EnsureIndexTemplate puts the template if it does not exist, or if the existing one has a lower version.
+// It returns false when the template is up to date, so that it can be called at every start-up.
+func EnsureIndexTemplate(ctx context.Context, es *elasticsearch.Client, name string, template IndexTemplate) (bool, error) {
+	existing, err := GetIndexTemplate(ctx, es, name)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && existing.Version >= template.Version {
+		return false, nil
+	}
+	return PutIndexTemplate(ctx, es, name, template)
+}
+
+// EnsureComponentTemplate puts the template if it does not exist, or if the existing one has a lower version.
+func EnsureComponentTemplate(ctx context.Context, es *elasticsearch.Client, name string, template ComponentTemplate) (bool, error) {
+	existing, err := GetComponentTemplate(ctx, es, name)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && existing.Version >= template.Version {
+		return false, nil
+	}
+	return PutComponentTemplate(ctx, es, name, template)
+}
@@ -0,0 +1,196 @@
+package elasticsearch
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeIndex resolves the name of a time-based rolling index, such as "events-2026.10.16", from a timestamp field of the model or from the current time.
+// The layout is a Go time layout, such as "2006.01.02" for daily indices or "2006.01" for monthly indices.
+type TimeIndex struct {
+	Prefix     string
+	Layout     string
+	Location   *time.Location
+	Now        func() time.Time
+	fieldIndex []int
+	jsonName   string
+}
+
+func NewTimeIndex(prefix string, layout string, modelType reflect.Type, options ...string) *TimeIndex {
+	var fieldIndex []int
+	var jsonName string
+	if modelType != nil && len(options) > 0 && len(options[0]) > 0 {
+		jsonName = options[0]
+		fields := getStructFields(modelType)
+		if i, ok := fields.byName[options[0]]; ok {
+			fieldIndex, jsonName = fields.list[i].Index, fields.list[i].JsonName
+		}
+	}
+	return &TimeIndex{Prefix: prefix, Layout: layout, Location: time.UTC, Now: time.Now, fieldIndex: fieldIndex, jsonName: jsonName}
+}
+
+func (t *TimeIndex) IndexName(model interface{}) string {
+	return t.Format(t.timeOf(model))
+}
+
+func (t *TimeIndex) Format(date time.Time) string {
+	if t.Location != nil {
+		date = date.In(t.Location)
+	}
+	return t.Prefix + date.Format(t.Layout)
+}
+
+// Wildcard returns the expression targeting all indices, such as "events-*".
+func (t *TimeIndex) Wildcard() string {
+	return t.Prefix + "*"
+}
+
+// Range returns the names of the indices covering the period from start to end.
+func (t *TimeIndex) Range(start time.Time, end time.Time) []string {
+	var indices []string
+	if end.Before(start) {
+		return indices
+	}
+	last := t.Format(end)
+	for d := t.truncate(start); ; d = t.next(d) {
+		name := t.Format(d)
+		if len(indices) == 0 || indices[len(indices)-1] != name {
+			indices = append(indices, name)
+		}
+		if name == last || d.After(end) {
+			break
+		}
+	}
+	return indices
+}
+
+// DateMath returns the date math expression of the current index, such as "<events-{now/d{yyyy.MM.dd}}>".
+// Each offset targets a previous period: DateMath(1) is the index of yesterday for daily indices.
+// The expression is not URL encoded: the searches of this package encode it in the path of their requests, while a request body, such as the header of a multi search, takes it as it is.
+func (t *TimeIndex) DateMath(offsets ...int) string {
+	unit := t.unit()
+	now := "now"
+	if len(offsets) > 0 && offsets[0] > 0 {
+		now = "now-" + strconv.Itoa(offsets[0]) + unit
+	}
+	zone := ""
+	if t.Location != nil && t.Location != time.UTC {
+		zone = "|" + t.Location.String()
+	}
+	return "<" + escapeDateMath(t.Prefix) + "{" + now + "/" + unit + "{" + JavaDateFormat(t.Layout) + zone + "}}>"
+}
+
+// Recent returns the date math expressions of the current index and of the count-1 previous indices.
+func (t *TimeIndex) Recent(count int) []string {
+	var indices []string
+	for i := 0; i < count; i++ {
+		indices = append(indices, t.DateMath(i))
+	}
+	return indices
+}
+
+func (t *TimeIndex) timeOf(model interface{}) time.Time {
+	if len(t.jsonName) > 0 {
+		if m, ok := model.(map[string]interface{}); ok {
+			if d, ok := toTime(m[t.jsonName]); ok {
+				return d
+			}
+		} else if len(t.fieldIndex) > 0 && model != nil {
+			if f, ok := FieldByIndex(reflect.ValueOf(model), t.fieldIndex); ok {
+				if d, ok := toTime(f.Interface()); ok {
+					return d
+				}
+			}
+		}
+	}
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+func (t *TimeIndex) unit() string {
+	switch {
+	case strings.Contains(t.Layout, "15"):
+		return "h"
+	case strings.Contains(t.Layout, "02"):
+		return "d"
+	case strings.Contains(t.Layout, "01"):
+		return "M"
+	default:
+		return "y"
+	}
+}
+
+// truncate returns the start of the period of d, so that stepping by months from January 31 does not skip February.
+func (t *TimeIndex) truncate(d time.Time) time.Time {
+	if t.Location != nil {
+		d = d.In(t.Location)
+	}
+	switch t.unit() {
+	case "h":
+		return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), 0, 0, 0, d.Location())
+	case "d":
+		return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
+	case "M":
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, d.Location())
+	default:
+		return time.Date(d.Year(), 1, 1, 0, 0, 0, 0, d.Location())
+	}
+}
+
+func (t *TimeIndex) next(d time.Time) time.Time {
+	switch t.unit() {
+	case "h":
+		return d.Add(time.Hour)
+	case "d":
+		return d.AddDate(0, 0, 1)
+	case "M":
+		return d.AddDate(0, 1, 0)
+	default:
+		return d.AddDate(1, 0, 0)
+	}
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, !d.IsZero()
+	case *time.Time:
+		if d != nil {
+			return *d, !d.IsZero()
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, d); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// JavaDateFormat converts the common elements of a Go time layout to the date format of Elasticsearch, for example "2006.01.02" to "yyyy.MM.dd".
+func JavaDateFormat(layout string) string {
+	r := strings.NewReplacer("2006", "yyyy", "01", "MM", "02", "dd", "15", "HH", "04", "mm", "05", "ss")
+	return r.Replace(layout)
+}
+
+func escapeDateMath(s string) string {
+	r := strings.NewReplacer("{", "\\{", "}", "\\}")
+	return r.Replace(s)
+}
+
+// pathIndices encodes the date math expressions, such as "<events-{now/d}>", for the path of a request; the other names are kept as they are.
+func pathIndices(indices ...string) []string {
+	result := make([]string, len(indices))
+	for i, name := range indices {
+		if strings.HasPrefix(name, "<") {
+			result[i] = url.PathEscape(name)
+		} else {
+			result[i] = name
+		}
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package elasticsearch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func utcDate(year int, month time.Month, day int, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestTimeIndexRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		start    time.Time
+		end      time.Time
+		expected []string
+	}{
+		{"daily", "2006.01.02", utcDate(2024, 1, 30, 10), utcDate(2024, 2, 2, 1), []string{"events-2024.01.30", "events-2024.01.31", "events-2024.02.01", "events-2024.02.02"}},
+		{"same day", "2006.01.02", utcDate(2024, 1, 30, 1), utcDate(2024, 1, 30, 23), []string{"events-2024.01.30"}},
+		{"monthly from the end of a month", "2006.01", utcDate(2024, 1, 31, 0), utcDate(2024, 3, 1, 0), []string{"events-2024.01", "events-2024.02", "events-2024.03"}},
+		{"hourly", "2006.01.02.15", utcDate(2024, 1, 31, 22), utcDate(2024, 2, 1, 0), []string{"events-2024.01.31.22", "events-2024.01.31.23", "events-2024.02.01.00"}},
+		{"yearly", "2006", utcDate(2023, 12, 31, 0), utcDate(2024, 1, 1, 0), []string{"events-2023", "events-2024"}},
+		{"end before start", "2006.01.02", utcDate(2024, 2, 2, 0), utcDate(2024, 1, 30, 0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewTimeIndex("events-", tt.layout, nil)
+			if indices := index.Range(tt.start, tt.end); !reflect.DeepEqual(indices, tt.expected) {
+				t.Errorf("indices %v, expected %v", indices, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTimeIndexRangeLocation(t *testing.T) {
+	index := NewTimeIndex("events-", "2006.01.02", nil)
+	index.Location = time.FixedZone("ICT", 7*60*60)
+	indices := index.Range(utcDate(2024, 1, 30, 20), utcDate(2024, 1, 31, 10))
+	expected := []string{"events-2024.01.31"}
+	if !reflect.DeepEqual(indices, expected) {
+		t.Errorf("indices %v, expected %v", indices, expected)
+	}
+}
+
+func TestTimeIndexTruncate(t *testing.T) {
+	d := time.Date(2024, 2, 29, 13, 45, 30, 0, time.UTC)
+	tests := []struct {
+		layout   string
+		expected time.Time
+	}{
+		{"2006.01.02.15", utcDate(2024, 2, 29, 13)},
+		{"2006.01.02", utcDate(2024, 2, 29, 0)},
+		{"2006.01", utcDate(2024, 2, 1, 0)},
+		{"2006", utcDate(2024, 1, 1, 0)},
+	}
+	for _, tt := range tests {
+		index := NewTimeIndex("events-", tt.layout, nil)
+		if truncated := index.truncate(d); !truncated.Equal(tt.expected) {
+			t.Errorf("%s: %v, expected %v", tt.layout, truncated, tt.expected)
+		}
+	}
+}
+
+func TestTimeIndexDateMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		layout   string
+		location *time.Location
+		offset   []int
+		expected string
+	}{
+		{"daily", "events-", "2006.01.02", time.UTC, nil, "<events-{now/d{yyyy.MM.dd}}>"},
+		{"daily offset", "events-", "2006.01.02", time.UTC, []int{1}, "<events-{now-1d/d{yyyy.MM.dd}}>"},
+		{"zero offset", "events-", "2006.01.02", time.UTC, []int{0}, "<events-{now/d{yyyy.MM.dd}}>"},
+		{"monthly", "events-", "2006.01", time.UTC, []int{2}, "<events-{now-2M/M{yyyy.MM}}>"},
+		{"hourly", "events-", "2006.01.02.15", time.UTC, nil, "<events-{now/h{yyyy.MM.dd.HH}}>"},
+		{"yearly", "events-", "2006", time.UTC, nil, "<events-{now/y{yyyy}}>"},
+		{"time zone", "events-", "2006.01.02", time.FixedZone("ICT", 7*60*60), nil, "<events-{now/d{yyyy.MM.dd|ICT}}>"},
+		{"escaped prefix", "logs{a}-", "2006.01.02", time.UTC, nil, "<logs\\{a\\}-{now/d{yyyy.MM.dd}}>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewTimeIndex(tt.prefix, tt.layout, nil)
+			index.Location = tt.location
+			if expression := index.DateMath(tt.offset...); expression != tt.expected {
+				t.Errorf("expression %s, expected %s", expression, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPathIndices(t *testing.T) {
+	tests := []struct {
+		indices  []string
+		expected []string
+	}{
+		{[]string{"events"}, []string{"events"}},
+		{[]string{"events-*", "logs"}, []string{"events-*", "logs"}},
+		{[]string{"<events-{now/d{yyyy.MM.dd}}>"}, []string{"%3Cevents-%7Bnow%2Fd%7Byyyy.MM.dd%7D%7D%3E"}},
+		{[]string{"events", "<events-{now-1d/d}>"}, []string{"events", "%3Cevents-%7Bnow-1d%2Fd%7D%3E"}},
+		{nil, []string{}},
+	}
+	for _, tt := range tests {
+		if indices := pathIndices(tt.indices...); !reflect.DeepEqual(indices, tt.expected) {
+			t.Errorf("%v: %v, expected %v", tt.indices, indices, tt.expected)
+		}
+	}
+}
@@ -10,6 +10,7 @@ type Updater struct {
 	client    *elasticsearch.Client
 	indexName string
 	Map       func(ctx context.Context, model interface{}) (interface{}, error)
+	Audit     *Audit
 }
 
 func NewUpdater(client *elasticsearch.Client, indexName string, options ...func(context.Context, interface{}) (interface{}, error)) *Updater {
@@ -21,13 +22,17 @@ func NewUpdater(client *elasticsearch.Client, indexName string, options ...func(
 }
 
 func (w *Updater) Write(ctx context.Context, model interface{}) error {
+	ctx = withDefaultAudit(ctx, w.Audit)
+	model = SetAuditFields(ctx, model, false)
 	modelType := reflect.TypeOf(model)
 	if w.Map != nil {
+		id := documentId(modelType, model)
+		created := GetMetadata(modelType).emptyCreated(model)
 		m2, er0 := w.Map(ctx, model)
 		if er0 != nil {
 			return er0
 		}
-		_, er1 := UpdateOne(ctx, w.client, w.indexName, modelType, m2)
+		_, er1 := updateOne(ctx, w.client, w.indexName, id, modelType, m2, created)
 		return er1
 	}
 	_, er2 := UpdateOne(ctx, w.client, w.indexName, modelType, model)
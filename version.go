@@ -0,0 +1,85 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"net/http"
+)
+
+// ErrVersionConflict is returned by the versioned writes of Repository when the stored version of the document is not the version of the model, because the document was changed since the model was loaded,
+// and by SaveOne when the document is still changed concurrently after its retries.
+var ErrVersionConflict = errors.New("version conflict: the document was changed concurrently")
+
+type versionKey struct{}
+
+type expectedVersion struct {
+	field   string
+	version int64
+}
+
+// withExpectedVersion makes the update or the save of the document conditional on the stored value of the version field, given by its json name.
+func withExpectedVersion(ctx context.Context, field string, version int64) context.Context {
+	return context.WithValue(ctx, versionKey{}, &expectedVersion{field: field, version: version})
+}
+
+func getExpectedVersion(ctx context.Context) *expectedVersion {
+	if ctx == nil {
+		return nil
+	}
+	e, _ := ctx.Value(versionKey{}).(*expectedVersion)
+	return e
+}
+
+// checkVersion reads the version of the stored document and returns its sequence number and primary term, for a write conditional on them.
+// It returns ErrVersionConflict if the stored version is not the expected version.
+func checkVersion(ctx context.Context, es *elasticsearch.Client, indexName string, documentID string, e *expectedVersion) (int, int, error) {
+	req := esapi.GetRequest{
+		Index:          indexName,
+		DocumentID:     documentID,
+		SourceIncludes: []string{e.field},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return 0, 0, errors.New("document ID not exists in the index")
+	}
+	if res.IsError() {
+		return 0, 0, errors.New("response error")
+	}
+	var r struct {
+		SeqNo       int                    `json:"_seq_no"`
+		PrimaryTerm int                    `json:"_primary_term"`
+		Source      map[string]interface{} `json:"_source"`
+	}
+	if err := decode(ctx, res.Body, &r); err != nil {
+		return 0, 0, err
+	}
+	if !e.matches(r.Source[e.field]) {
+		return 0, 0, ErrVersionConflict
+	}
+	return r.SeqNo, r.PrimaryTerm, nil
+}
+
+// matches reports whether the decoded value of the version field is the expected version; a missing version matches the version 0.
+func (e *expectedVersion) matches(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return e.version == 0
+	case float64:
+		return x == float64(e.version)
+	case json.Number:
+		n, err := x.Int64()
+		return err == nil && n == e.version
+	case int64:
+		return x == e.version
+	case int:
+		return int64(x) == e.version
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	es "github.com/elastic/go-elasticsearch/v7"
 	"reflect"
+	"time"
 )
 
 type Mapper interface {
@@ -16,8 +17,11 @@ type Writer struct {
 	*Loader
 	maps         map[string]string
 	versionField string
-	versionIndex int
+	versionIndex []int
 	Mapper       Mapper
+	GetIndex     func(model interface{}) string
+	Pipeline     string
+	Audit        *Audit
 }
 
 func NewWriter(client *es.Client, indexName string, modelType reflect.Type, options ...string) *Writer {
@@ -35,36 +39,328 @@ func NewWriterWithMapper(client *es.Client, indexName string, modelType reflect.
 		versionField = options[0]
 	}
 	if len(versionField) > 0 {
-		index, _ := FindFieldByName(modelType, versionField)
-		if index >= 0 {
-			return &Writer{Loader: loader, maps: MakeMapJson(modelType), versionField: versionField, versionIndex: index}
+		if i, ok := getStructFields(modelType).byName[versionField]; ok {
+			return &Writer{Loader: loader, maps: MakeMapJson(modelType), Mapper: mapper, versionField: versionField, versionIndex: getStructFields(modelType).list[i].Index}
 		}
 	}
-	return &Writer{Loader: loader, maps: MakeMapJson(modelType), Mapper: mapper, versionField: "", versionIndex: -1}
+	meta := GetMetadata(modelType)
+	return &Writer{Loader: loader, maps: meta.JsonNames, Mapper: mapper, versionField: "", versionIndex: meta.Version}
+}
+
+// NewWriterWithIndex creates a writer for time-based rolling indices: each document is inserted into the index returned by getIndex, and indexName, usually a wildcard such as "events-*", is used to find the documents to load, update or delete.
+func NewWriterWithIndex(client *es.Client, indexName string, getIndex func(model interface{}) string, modelType reflect.Type, options ...string) *Writer {
+	writer := NewWriterWithMapper(client, indexName, modelType, nil, options...)
+	writer.GetIndex = getIndex
+	writer.alias = true
+	return writer
+}
+
+func (m *Writer) index(model interface{}) string {
+	if m.GetIndex != nil {
+		return m.GetIndex(model)
+	}
+	return m.indexName
+}
+
+func (m *Writer) existingIndex(ctx context.Context, id string) (string, error) {
+	if m.alias || m.GetIndex != nil {
+		return FindIndexById(ctx, m.client, m.indexName, id)
+	}
+	return m.indexName, nil
+}
+
+// context returns the context with the codec, the encryptor and the audit of the writer, unless the context already has them.
+func (m *Writer) context(ctx context.Context) context.Context {
+	return withDefaultAudit(m.Loader.context(ctx), m.Audit)
 }
 
 func (m *Writer) Insert(ctx context.Context, model interface{}) (int64, error) {
-	return InsertOne(ctx, m.client, m.indexName, m.modelType, model)
+	ctx = m.context(ctx)
+	r, _, err := m.insert(ctx, model)
+	return r, err
+}
+
+// insert inserts the model and returns its version and the id of the document, generated by Elasticsearch if the model has no id.
+func (m *Writer) insert(ctx context.Context, model interface{}) (int64, string, error) {
+	model = SetAuditFields(ctx, model, true)
+	id := documentId(m.modelType, model)
+	model, err := m.toDb(ctx, model)
+	if err != nil {
+		return -1, "", err
+	}
+	return createOne(ctx, m.client, m.index(model), m.modelType, id, model, getPipeline(ctx, m.Pipeline))
 }
 
 func (m *Writer) Update(ctx context.Context, model interface{}) (int64, error) {
-	return UpdateOne(ctx, m.client, m.indexName, m.modelType, model)
+	ctx = m.context(ctx)
+	if len(m.idIndex) == 0 {
+		return 0, fmt.Errorf("missing document ID in the object")
+	}
+	created := GetMetadata(m.modelType).emptyCreated(model)
+	model = SetAuditFields(ctx, model, false)
+	id := getString(model, m.idIndex)
+	model, err := m.toDb(ctx, model)
+	if err != nil {
+		return -1, err
+	}
+	if m.alias || m.GetIndex != nil {
+		indexName, err := m.existingIndex(ctx, id)
+		if err != nil {
+			return -1, err
+		}
+		if len(indexName) == 0 {
+			return -1, fmt.Errorf("document ID not exists in the index")
+		}
+		return updateOne(ctx, m.client, indexName, id, m.modelType, model, created)
+	}
+	return updateOne(ctx, m.client, m.index(model), id, m.modelType, model, created)
 }
 func (m *Writer) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
-	return PatchOne(ctx, m.client, m.indexName, MapToDBObject(model, m.maps))
+	ctx = m.context(ctx)
+	obj := MapToDBObject(model, m.maps)
+	SetAuditMap(ctx, m.modelType, obj)
+	obj, err := m.mapToDb(ctx, obj)
+	if err != nil {
+		return -1, err
+	}
+	if err := EncryptBody(ctx, m.modelType, obj); err != nil {
+		return -1, err
+	}
+	if m.alias || m.GetIndex != nil {
+		id, _ := obj["_id"].(string)
+		indexName, err := m.existingIndex(ctx, id)
+		if err != nil {
+			return -1, err
+		}
+		if len(indexName) == 0 {
+			return -1, fmt.Errorf("document ID not exists in the index")
+		}
+		return PatchOne(ctx, m.client, indexName, obj)
+	}
+	return PatchOne(ctx, m.client, m.index(obj), obj)
+}
+
+// JsonPatch applies the operations of a JSON Patch (RFC 6902) to the document, see JsonPatchOne. The audit fields updatedAt and updatedBy are set.
+// If Mapper is set, the values of the operations on the top level fields are mapped as the document of a Patch; the values of the nested paths are not mapped.
+func (m *Writer) JsonPatch(ctx context.Context, id interface{}, operations []PatchOperation) (int64, error) {
+	ctx = m.context(ctx)
+	if err := ValidatePatch(m.modelType, operations); err != nil {
+		return -1, err
+	}
+	audit := make(map[string]interface{})
+	SetAuditMap(ctx, m.modelType, audit)
+	for k, v := range audit {
+		operations = append(operations, PatchOperation{Op: "add", Path: "/" + k, Value: v})
+	}
+	operations, err := m.mapPatch(ctx, operations)
+	if err != nil {
+		return -1, err
+	}
+	operations, err = EncryptPatch(ctx, m.modelType, operations)
+	if err != nil {
+		return -1, err
+	}
+	script, err := JsonPatchScript(operations)
+	if err != nil {
+		return -1, err
+	}
+	return m.patchByScript(ctx, id.(string), script)
 }
 
+// MergePatch applies a JSON Merge Patch (RFC 7386) to the document, see MergePatchOne. The audit fields updatedAt and updatedBy are set.
+func (m *Writer) MergePatch(ctx context.Context, id interface{}, patch map[string]interface{}) (int64, error) {
+	ctx = m.context(ctx)
+	if err := ValidateMergePatch(m.modelType, patch); err != nil {
+		return -1, err
+	}
+	merged := make(map[string]interface{}, len(patch)+2)
+	for k, v := range patch {
+		merged[k] = v
+	}
+	SetAuditMap(ctx, m.modelType, merged)
+	merged, err := m.mapToDb(ctx, merged)
+	if err != nil {
+		return -1, err
+	}
+	if err := EncryptBody(ctx, m.modelType, merged); err != nil {
+		return -1, err
+	}
+	return m.patchByScript(ctx, id.(string), MergePatchScript(merged))
+}
+
+func (m *Writer) patchByScript(ctx context.Context, id string, script Script) (int64, error) {
+	indexName, err := m.existingIndex(ctx, id)
+	if err != nil {
+		return -1, err
+	}
+	if len(indexName) == 0 {
+		return -1, fmt.Errorf("document ID not exists in the index")
+	}
+	return ScriptOne(ctx, m.client, indexName, id, script)
+}
+
+// Delete deletes the document, or only marks it as deleted if the model has a soft delete field, see SoftDelete.
 func (m *Writer) Delete(ctx context.Context, id interface{}) (int64, error) {
+	ctx = m.context(ctx)
+	sid := id.(string)
+	indexName, err := m.existingIndex(ctx, sid)
+	if err != nil || len(indexName) == 0 {
+		return 0, err
+	}
+	if m.SoftDelete != nil {
+		return ScriptOne(ctx, m.client, indexName, sid, m.SoftDelete.DeleteScript(ctx, m.modelType))
+	}
+	return DeleteOne(ctx, m.client, indexName, sid)
+}
+
+// Restore removes the deleted mark of a soft-deleted document.
+func (m *Writer) Restore(ctx context.Context, id interface{}) (int64, error) {
+	ctx = m.context(ctx)
+	if m.SoftDelete == nil {
+		return -1, fmt.Errorf("soft delete is not enabled for the model")
+	}
 	sid := id.(string)
-	return DeleteOne(ctx, m.client, m.indexName, sid)
+	indexName, err := m.existingIndex(ctx, sid)
+	if err != nil || len(indexName) == 0 {
+		return 0, err
+	}
+	return ScriptOne(ctx, m.client, indexName, sid, m.SoftDelete.RestoreScript(ctx, m.modelType))
+}
+
+// Purge deletes permanently the documents soft-deleted before the time, and returns the number of deleted documents.
+func (m *Writer) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ctx = m.context(ctx)
+	r, err := PurgeDeleted(ctx, m.client, m.indexName, m.SoftDelete, before)
+	if err != nil {
+		return -1, err
+	}
+	return r.Deleted, nil
 }
 
+// Save inserts or replaces the document, see SaveOne; the createdAt and createdBy of an existing document are kept, and set in the model if it is a pointer.
 func (m *Writer) Save(ctx context.Context, model interface{}) (int64, error) {
-	idIndex, _, _ := FindIdField(m.modelType)
-	if idIndex < 0 {
+	ctx = m.context(ctx)
+	if len(m.idIndex) == 0 {
 		return 0, fmt.Errorf("missing document ID in the object")
 	}
-	modelValue := reflect.ValueOf(model)
-	id := modelValue.Field(idIndex).String()
-	return UpsertOne(ctx, m.client, m.indexName, id, model)
+	created := GetMetadata(m.modelType).emptyCreated(model)
+	model = SetAuditFields(ctx, model, true)
+	id := getString(model, m.idIndex)
+	doc, err := m.toDb(ctx, model)
+	if err != nil {
+		return -1, err
+	}
+	indexName := m.index(doc)
+	if m.alias || m.GetIndex != nil {
+		existing, err := m.existingIndex(ctx, id)
+		if err != nil {
+			return -1, err
+		}
+		if len(existing) > 0 {
+			indexName = existing
+		}
+	}
+	successful, stored, err := saveOne(ctx, m.client, indexName, m.modelType, id, doc, created, getPipeline(ctx, m.Pipeline))
+	if err != nil || len(stored) == 0 {
+		return successful, err
+	}
+	return successful, setStored(ctx, model, stored)
+}
+
+// UpdateByScript runs the script on the document, see ScriptOne. If upsert is true, the script also runs on an empty document when the document does not exist,
+// which is created in the index of GetIndex for an empty document, or in the write index of the alias.
+func (m *Writer) UpdateByScript(ctx context.Context, id interface{}, script Script, upsert bool) (int64, error) {
+	ctx = m.context(ctx)
+	sid := id.(string)
+	indexName, err := m.existingIndex(ctx, sid)
+	if err != nil {
+		return -1, err
+	}
+	if upsert {
+		doc := map[string]interface{}{}
+		if len(indexName) == 0 {
+			indexName = m.index(doc)
+		}
+		return ScriptUpsertOne(ctx, m.client, indexName, sid, script, doc)
+	}
+	if len(indexName) == 0 {
+		return -1, fmt.Errorf("document ID not exists in the index")
+	}
+	return ScriptOne(ctx, m.client, indexName, sid, script)
+}
+
+func (m *Writer) Increment(ctx context.Context, id interface{}, field string, delta interface{}) (int64, error) {
+	return m.UpdateByScript(ctx, id, IncrementScript(m.jsonName(field), delta), false)
+}
+
+func (m *Writer) Append(ctx context.Context, id interface{}, field string, values ...interface{}) (int64, error) {
+	return m.UpdateByScript(ctx, id, AppendScript(m.jsonName(field), false, values...), false)
+}
+
+func (m *Writer) AddToSet(ctx context.Context, id interface{}, field string, values ...interface{}) (int64, error) {
+	return m.UpdateByScript(ctx, id, AppendScript(m.jsonName(field), true, values...), false)
+}
+
+func (m *Writer) Remove(ctx context.Context, id interface{}, field string, values ...interface{}) (int64, error) {
+	return m.UpdateByScript(ctx, id, RemoveScript(m.jsonName(field), values...), false)
+}
+
+func (m *Writer) SetIfAbsent(ctx context.Context, id interface{}, field string, value interface{}) (int64, error) {
+	return m.UpdateByScript(ctx, id, SetIfAbsentScript(m.jsonName(field), value), false)
+}
+
+// toDb maps the model by Mapper.ModelToDb, if the writer has a mapper.
+func (m *Writer) toDb(ctx context.Context, model interface{}) (interface{}, error) {
+	if m.Mapper == nil {
+		return model, nil
+	}
+	return m.Mapper.ModelToDb(ctx, model)
+}
+
+// mapToDb maps the document of a patch by Mapper.ModelToDb; the result is used only if it is a map, since the mapper may support only the models.
+func (m *Writer) mapToDb(ctx context.Context, model map[string]interface{}) (map[string]interface{}, error) {
+	if m.Mapper == nil {
+		return model, nil
+	}
+	r, err := m.Mapper.ModelToDb(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := r.(map[string]interface{}); ok {
+		return obj, nil
+	}
+	return model, nil
+}
+
+// mapPatch maps the value of each operation on a top level field by mapToDb, as a patch of this field only.
+func (m *Writer) mapPatch(ctx context.Context, operations []PatchOperation) ([]PatchOperation, error) {
+	if m.Mapper == nil {
+		return operations, nil
+	}
+	result := make([]PatchOperation, len(operations))
+	for i, op := range operations {
+		result[i] = op
+		if op.Op == "remove" || op.Op == "move" {
+			continue
+		}
+		path, err := ParseJsonPointer(op.Path)
+		if err != nil || len(path) != 1 {
+			continue
+		}
+		obj, err := m.mapToDb(ctx, map[string]interface{}{path[0]: op.Value})
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := obj[path[0]]; ok {
+			result[i].Value = v
+		}
+	}
+	return result, nil
+}
+
+func (m *Writer) jsonName(field string) string {
+	if name, ok := m.maps[field]; ok {
+		return name
+	}
+	return field
 }
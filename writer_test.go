@@ -0,0 +1,195 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/elastic/go-elasticsearch/v7"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// fakeTransport records the requests of a client and answers them by respond, or with a successful write.
+type fakeTransport struct {
+	requests []recordedRequest
+	respond  func(req *http.Request) (int, string)
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, `{"_id":"generated","_version":1,"result":"created","_shards":{"successful":1}}`
+	if req.URL.Path == "/" {
+		body = `{"version":{"number":"7.17.10","build_flavor":"default"},"tagline":"You Know, for Search"}`
+	} else {
+		var data []byte
+		if req.Body != nil {
+			data, _ = io.ReadAll(req.Body)
+		}
+		t.requests = append(t.requests, recordedRequest{Method: req.Method, Path: req.URL.Path, Body: data})
+		if t.respond != nil {
+			status, body = t.respond(req)
+		}
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func newTestClient(t *testing.T, transport *fakeTransport) *elasticsearch.Client {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{"http://localhost:9200"}, Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func decodeBody(t *testing.T, body []byte) map[string]interface{} {
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		t.Fatalf("invalid body %s: %v", body, err)
+	}
+	return m
+}
+
+type mappedUser struct {
+	Id   string `json:"id" bson:"_id"`
+	Name string `json:"name"`
+}
+
+// mapMapper maps a user to a map with the name in upper case, as a mapper to another representation.
+type mapMapper struct{}
+
+func (mapMapper) DbToModel(ctx context.Context, model interface{}) (interface{}, error) {
+	return model, nil
+}
+
+func (mapMapper) ModelToDb(ctx context.Context, model interface{}) (interface{}, error) {
+	user := reflect.Indirect(reflect.ValueOf(model)).Interface().(mappedUser)
+	return map[string]interface{}{"id": user.Id, "name": strings.ToUpper(user.Name), "mapped": true}, nil
+}
+
+func TestWriteMappedMap(t *testing.T) {
+	transport := &fakeTransport{}
+	writer := NewWriterWithMapper(newTestClient(t, transport), "users", reflect.TypeOf(mappedUser{}), mapMapper{})
+	ctx := context.Background()
+	if _, err := writer.Insert(ctx, &mappedUser{Id: "1", Name: "peter"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if _, err := writer.Save(ctx, &mappedUser{Id: "2", Name: "mary"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := writer.Update(ctx, &mappedUser{Id: "3", Name: "john"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	expected := []struct {
+		path string
+		doc  map[string]interface{}
+	}{
+		{"/users/_doc/1/_create", map[string]interface{}{"name": "PETER", "mapped": true}},
+		{"/users/_doc/2", map[string]interface{}{"name": "MARY", "mapped": true}},
+		{"/users/_doc/3/_update", map[string]interface{}{"doc": map[string]interface{}{"name": "JOHN", "mapped": true}}},
+	}
+	if len(transport.requests) != len(expected) {
+		t.Fatalf("requests %v", transport.requests)
+	}
+	for i, e := range expected {
+		r := transport.requests[i]
+		if r.Path != e.path {
+			t.Errorf("path %s, expected %s", r.Path, e.path)
+		}
+		if doc := decodeBody(t, r.Body); !reflect.DeepEqual(doc, e.doc) {
+			t.Errorf("%s: document %v, expected %v", r.Path, doc, e.doc)
+		}
+	}
+}
+
+func TestWriteManyMappedMap(t *testing.T) {
+	transport := &fakeTransport{respond: func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"errors":true,"items":[{"create":{"_id":"2","status":409,"error":{"type":"version_conflict_engine_exception"}}},{"create":{"_id":"1","status":201}}]}`
+	}}
+	repository := NewRepositoryWithMapper[mappedUser, string](newTestClient(t, transport), "users", mapMapper{})
+	success, failure, err := repository.InsertMany(context.Background(), []mappedUser{{Id: "1", Name: "peter"}, {Id: "2", Name: "mary"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(success, []int{0}) || !reflect.DeepEqual(failure, []int{1}) {
+		t.Errorf("success %v, failure %v", success, failure)
+	}
+	if len(transport.requests) != 1 {
+		t.Fatalf("requests %v", transport.requests)
+	}
+	lines := strings.Split(strings.TrimSpace(string(transport.requests[0].Body)), "\n")
+	expected := []map[string]interface{}{
+		{"create": map[string]interface{}{"_id": "1"}},
+		{"name": "PETER", "mapped": true},
+		{"create": map[string]interface{}{"_id": "2"}},
+		{"name": "MARY", "mapped": true},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("bulk body %s", transport.requests[0].Body)
+	}
+	for i, line := range lines {
+		if got := decodeBody(t, []byte(line)); !reflect.DeepEqual(got, expected[i]) {
+			t.Errorf("line %d: %v, expected %v", i, got, expected[i])
+		}
+	}
+}
+
+type auditedUser struct {
+	Id        string `json:"id" bson:"_id"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"createdBy" es:",createdBy"`
+	UpdatedBy string `json:"updatedBy" es:",updatedBy"`
+}
+
+func TestWriteAudit(t *testing.T) {
+	transport := &fakeTransport{respond: func(req *http.Request) (int, string) {
+		if strings.HasSuffix(req.URL.Path, "/_bulk") {
+			return http.StatusOK, `{"errors":false,"items":[{"create":{"_id":"3","status":201}}]}`
+		}
+		return http.StatusOK, `{"_id":"1","_version":1,"result":"created","_shards":{"successful":1}}`
+	}}
+	client := newTestClient(t, transport)
+	audit := &Audit{GetUser: func(ctx context.Context) string { return "admin" }}
+	ctx := context.Background()
+	inserter := NewInserter(client, "users")
+	inserter.Audit = audit
+	if err := inserter.Write(ctx, &auditedUser{Id: "1", Name: "peter"}); err != nil {
+		t.Fatal(err)
+	}
+	writer := NewElasticSearchWriter(client, "users")
+	writer.Audit = audit
+	if err := writer.Write(ctx, &auditedUser{Id: "2", Name: "mary"}); err != nil {
+		t.Fatal(err)
+	}
+	batch := NewBatchInserter(client, "users", reflect.TypeOf(auditedUser{}))
+	batch.Audit = audit
+	if _, _, err := batch.Write(ctx, []auditedUser{{Id: "3", Name: "john"}}); err != nil {
+		t.Fatal(err)
+	}
+	updater := NewUpdater(client, "users")
+	updater.Audit = audit
+	if err := updater.Write(ctx, &auditedUser{Id: "4", Name: "anna"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.requests) != 4 {
+		t.Fatalf("requests %v", transport.requests)
+	}
+	for _, r := range transport.requests[:3] {
+		lines := strings.Split(strings.TrimSpace(string(r.Body)), "\n")
+		if doc := decodeBody(t, []byte(lines[len(lines)-1])); doc["createdBy"] != "admin" || doc["updatedBy"] != "admin" {
+			t.Errorf("%s: document %v, expected createdBy and updatedBy admin", r.Path, doc)
+		}
+	}
+	if doc, _ := decodeBody(t, transport.requests[3].Body)["doc"].(map[string]interface{}); doc["updatedBy"] != "admin" {
+		t.Errorf("update %v, expected updatedBy admin", doc)
+	}
+}